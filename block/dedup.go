@@ -0,0 +1,108 @@
+package block
+
+import (
+	"sync/atomic"
+)
+
+type dedupQueue[K comparable, T any] struct {
+	*blockQueue[T]
+	key   func(value T) K
+	merge func(old, new T) T
+	index map[K]uint64
+	base  uint64
+}
+
+// NewDedup 创建去重阻塞队列，key 用于获取元素的 key，队列中同一个 key 最多只有一个元素，每一次 Dequeue 获取到的元素中每一个 key 最多出现一次
+// 添加元素时，如果队列中已经存在相同 key 的元素，则使用 merge 合并两个元素，合并之后的元素保留在原来的位置，merge 为 nil 时使用新的元素替换已存在的元素
+// merge 返回的元素的 key 需要与原来的元素相同；与已存在的元素合并不需要额外的空间，所以不会因为 WithMaxSize 而阻塞
+func NewDedup[K comparable, T any](key func(value T) K, merge func(old, new T) T, opts ...Option) Queue[T] {
+	var q = &dedupQueue[K, T]{}
+	q.blockQueue = newBlockQueue[T](opts...)
+	q.key = key
+	q.merge = merge
+	q.index = make(map[K]uint64)
+	q.blockQueue.dequeued = q.onDequeue
+	return q
+}
+
+func (dq *dedupQueue[K, T]) Enqueue(value T) bool {
+	return dq.EnqueueBatch([]T{value})
+}
+
+func (dq *dedupQueue[K, T]) EnqueueBatch(values []T) bool {
+	if atomic.LoadInt32(&dq.closed) == 1 {
+		dq.drop(len(values))
+		return false
+	}
+	if len(values) == 0 {
+		return true
+	}
+
+	dq.cond.L.Lock()
+	var pending = dq.coalesce(values)
+	for dq.options.max > 0 && len(dq.elements) > 0 && len(dq.elements)+len(pending) > dq.options.max {
+		if atomic.LoadInt32(&dq.closed) == 1 {
+			dq.cond.L.Unlock()
+			dq.drop(len(pending))
+			return false
+		}
+		dq.wait(true)
+		// 等待期间其它生产者可能已经添加了相同 key 的元素
+		pending = dq.coalesce(pending)
+	}
+
+	if len(pending) > 0 {
+		var pos = dq.base + uint64(len(dq.elements))
+		for i, value := range pending {
+			dq.index[dq.key(value)] = pos + uint64(i)
+		}
+		dq.elements = append(dq.elements, pending...)
+		dq.track(len(pending))
+		dq.observeEnqueue(len(pending))
+	}
+
+	dq.cond.L.Unlock()
+	dq.cond.Signal()
+	return true
+}
+
+// coalesce 将 values 中的元素与队列中已存在的相同 key 的元素合并，values 中相同 key 的元素也会合并
+// 返回需要添加到队列中的元素，调用方需要持有锁
+func (dq *dedupQueue[K, T]) coalesce(values []T) []T {
+	var pending = make([]T, 0, len(values))
+	var keys map[K]int
+	for _, value := range values {
+		var key = dq.key(value)
+		if pos, ok := dq.index[key]; ok {
+			var i = pos - dq.base
+			dq.elements[i] = dq.combine(dq.elements[i], value)
+			continue
+		}
+
+		if keys == nil {
+			keys = make(map[K]int, len(values))
+		}
+		if i, ok := keys[key]; ok {
+			pending[i] = dq.combine(pending[i], value)
+			continue
+		}
+		keys[key] = len(pending)
+		pending = append(pending, value)
+	}
+	return pending
+}
+
+func (dq *dedupQueue[K, T]) combine(old, new T) T {
+	if dq.merge == nil {
+		return new
+	}
+	return dq.merge(old, new)
+}
+
+// onDequeue 元素总是从队列的头部出队，调用方持有锁
+func (dq *dedupQueue[K, T]) onDequeue(values []T) {
+	for _, value := range values {
+		delete(dq.index, dq.key(value))
+	}
+	dq.base += uint64(len(values))
+}
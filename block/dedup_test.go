@@ -0,0 +1,81 @@
+package block_test
+
+import (
+	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/rate"
+	"reflect"
+	"testing"
+)
+
+type invalidation struct {
+	key   string
+	count int
+}
+
+func newDedupQueue(opts ...block.Option) block.Queue[invalidation] {
+	return block.NewDedup(func(value invalidation) string {
+		return value.key
+	}, func(old, new invalidation) invalidation {
+		return invalidation{key: old.key, count: old.count + new.count}
+	}, opts...)
+}
+
+func TestDedupQueue_Enqueue(t *testing.T) {
+	var q = newDedupQueue()
+
+	q.Enqueue(invalidation{key: "a", count: 1})
+	q.Enqueue(invalidation{key: "b", count: 1})
+	q.EnqueueBatch([]invalidation{{key: "a", count: 1}, {key: "c", count: 1}, {key: "c", count: 1}})
+
+	var items []invalidation
+	q.Dequeue(&items)
+	var expected = []invalidation{{key: "a", count: 2}, {key: "b", count: 1}, {key: "c", count: 2}}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+
+	// 出队之后可以再次添加相同 key 的元素
+	q.Enqueue(invalidation{key: "a", count: 1})
+	items = items[0:0]
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []invalidation{{key: "a", count: 1}}) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+}
+
+func TestDedupQueue_MaxSize(t *testing.T) {
+	var q = newDedupQueue(block.WithMaxSize(1))
+
+	q.Enqueue(invalidation{key: "a", count: 1})
+	// 合并已存在的元素不会因为队列已满而阻塞
+	q.Enqueue(invalidation{key: "a", count: 1})
+
+	var items []invalidation
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []invalidation{{key: "a", count: 2}}) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+}
+
+func TestDedupQueue_PartialDequeue(t *testing.T) {
+	var q = newDedupQueue(block.WithRateLimit(rate.NewLimiter(1000, 2)))
+	q.EnqueueBatch([]invalidation{{key: "a", count: 1}, {key: "b", count: 1}, {key: "c", count: 1}})
+
+	var items []invalidation
+	q.Dequeue(&items)
+	if len(items) != 2 {
+		t.Fatal("Dequeue 获取到的元素数量与预期不符", items)
+	}
+
+	// 剩余元素的位置发生变化之后依然可以合并
+	q.Enqueue(invalidation{key: "c", count: 1})
+	q.Enqueue(invalidation{key: "a", count: 1})
+
+	items = items[0:0]
+	for len(items) < 2 {
+		q.Dequeue(&items)
+	}
+	if !reflect.DeepEqual(items, []invalidation{{key: "c", count: 2}, {key: "a", count: 1}}) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+}
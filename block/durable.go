@@ -0,0 +1,168 @@
+package block
+
+import (
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/storage"
+	"time"
+)
+
+// DurableQueue 持久化阻塞队列
+// 入队的元素会先写入存储，再添加到队列中，重新打开队列时会恢复所有未出队（或者未确认）的元素
+// 调用 Close 之后 Dequeue 不再返回仍未出队的元素，这些元素会在下一次打开队列时重新入队
+type DurableQueue[T any] interface {
+	Queue[T]
+
+	// Ack 确认通过 Dequeue 获取到的所有元素都已处理完成，并将这些元素从存储中删除
+	// 只有设定了 WithAck 时才需要调用本方法
+	Ack() error
+}
+
+type durableQueue[T any] struct {
+	*blockQueue[T]
+	codec   codec.Codec[T]
+	storage storage.Storage
+	acked   uint64
+	head    uint64
+	next    uint64
+	stopped bool
+}
+
+// NewDurable 打开 dir 目录中的持久化阻塞队列，如果目录不存在则创建，元素存储在分段的预写日志中，参考 storage.NewLog
+// 元素出队（或者确认）之后会直接截断日志；如果写入存储失败，Enqueue 和 EnqueueBatch 会返回 false
+func NewDurable[T any](dir string, c codec.Codec[T], opts ...Option) (DurableQueue[T], error) {
+	var bq = newBlockQueue[T](opts...)
+	var s, err = storage.NewLog(dir, bq.options.wal...)
+	if err != nil {
+		return nil, err
+	}
+	return newDurableQueue[T](bq, s, c)
+}
+
+// NewWithStorage 使用存储 s 创建持久化阻塞队列，并从存储中恢复所有的元素
+// 如果写入存储失败，Enqueue 和 EnqueueBatch 会返回 false；调用 Close 时会关闭存储
+func NewWithStorage[T any](s storage.Storage, c codec.Codec[T], opts ...Option) (DurableQueue[T], error) {
+	return newDurableQueue[T](newBlockQueue[T](opts...), s, c)
+}
+
+func newDurableQueue[T any](bq *blockQueue[T], s storage.Storage, c codec.Codec[T]) (DurableQueue[T], error) {
+	var q = &durableQueue[T]{}
+	q.blockQueue = bq
+	q.codec = c
+	q.storage = s
+	q.next = 1
+
+	var first = true
+	if err := s.Load(func(record storage.Record) error {
+		var value, err = c.Unmarshal(record.Data)
+		if err != nil {
+			return err
+		}
+		if first {
+			q.head = record.ID
+			first = false
+		}
+		q.next = record.ID + 1
+		q.elements = append(q.elements, value)
+		return nil
+	}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if first {
+		q.head = q.next
+	}
+	q.acked = q.head
+	q.since = time.Now()
+	// 无法获取恢复的元素原本的入队时间，使用打开队列的时间代替
+	q.track(len(q.elements))
+
+	q.blockQueue.enqueued = q.onEnqueue
+	q.blockQueue.dequeued = q.onDequeue
+	return q, nil
+}
+
+func (dq *durableQueue[T]) onEnqueue(values []T) error {
+	if dq.stopped {
+		return storage.ErrClosed
+	}
+
+	var records = make([]storage.Record, len(values))
+	for i, value := range values {
+		var data, err = dq.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		records[i] = storage.Record{ID: dq.next + uint64(i), Data: data}
+	}
+	if err := dq.storage.Put(records...); err != nil {
+		return err
+	}
+	dq.next += uint64(len(values))
+	return nil
+}
+
+func (dq *durableQueue[T]) onDequeue(values []T) {
+	// 元素写入存储和添加到队列在同一次加锁中完成，并且元素按照入队的顺序出队，所以出队的元素的 ID 为 [head, head+len(values))
+	dq.head += uint64(len(values))
+	if !dq.options.ack {
+		// 删除失败时不更新 acked，下一次出队或者关闭队列时会再次删除这些元素
+		dq.deleteAcked()
+	}
+}
+
+func (dq *durableQueue[T]) Ack() error {
+	dq.cond.L.Lock()
+	defer dq.cond.L.Unlock()
+
+	if dq.stopped {
+		return storage.ErrClosed
+	}
+	return dq.deleteAcked()
+}
+
+// deleteAcked 从存储中删除 [acked, head) 之间的元素，调用方需要持有锁
+func (dq *durableQueue[T]) deleteAcked() error {
+	if dq.acked == dq.head {
+		return nil
+	}
+	if err := dq.storage.Delete(ids(dq.acked, dq.head)...); err != nil {
+		return err
+	}
+	dq.acked = dq.head
+	return nil
+}
+
+// Close 关闭队列及其存储
+// 存储关闭之后无法再删除出队的元素，所以队列中剩余的元素不会再通过 Dequeue 返回，它们会在下一次打开队列时重新入队
+func (dq *durableQueue[T]) Close() {
+	dq.cond.L.Lock()
+	if dq.stopped {
+		dq.cond.L.Unlock()
+		return
+	}
+	dq.stopped = true
+	if !dq.options.ack {
+		dq.deleteAcked()
+	}
+	dq.storage.Close()
+
+	var empty T
+	for i := range dq.elements {
+		dq.elements[i] = empty
+	}
+	dq.elements = dq.elements[:0]
+	if dq.times != nil {
+		dq.times = dq.times[:0]
+	}
+	dq.cond.L.Unlock()
+
+	dq.blockQueue.Close()
+}
+
+func ids(from, to uint64) []uint64 {
+	var nIds = make([]uint64, 0, to-from)
+	for id := from; id < to; id++ {
+		nIds = append(nIds, id)
+	}
+	return nIds
+}
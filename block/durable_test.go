@@ -0,0 +1,183 @@
+package block_test
+
+import (
+	"errors"
+	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/storage"
+	"github.com/smartwalle/queue/wal"
+	"testing"
+)
+
+func BenchmarkDurableQueue_Enqueue(b *testing.B) {
+	var q, err = block.NewDurable[int](b.TempDir(), codec.JSON[int](), block.WithWAL(wal.WithSyncPolicy(wal.SyncNever)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer q.Close()
+
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(i)
+	}
+}
+
+func TestDurableQueue_Recover(t *testing.T) {
+	var dir = t.TempDir()
+
+	var q, err = block.NewDurable[string](dir, codec.JSON[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("1")
+	q.Enqueue("2")
+
+	var items []string
+	q.Dequeue(&items)
+
+	q.EnqueueBatch([]string{"3", "4"})
+	q.Close()
+
+	if q, err = block.NewDurable[string](dir, codec.JSON[string]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if len(items) != 2 || items[0] != "3" || items[1] != "4" {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+}
+
+func TestDurableQueue_Ack(t *testing.T) {
+	var dir = t.TempDir()
+
+	var q, err = block.NewDurable[int](dir, codec.JSON[int](), block.WithAck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue(1)
+
+	var items []int
+	q.Dequeue(&items)
+	if err = q.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(2)
+	items = items[0:0]
+	q.Dequeue(&items)
+	// 没有调用 Ack，重新打开之后元素 2 会重新入队
+	q.Close()
+
+	if q, err = block.NewDurable[int](dir, codec.JSON[int](), block.WithAck()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if len(items) != 1 || items[0] != 2 {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+}
+
+func TestDurableQueue_Storage(t *testing.T) {
+	var s = storage.NewMemory()
+
+	var q, err = block.NewWithStorage[int](s, codec.JSON[int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.EnqueueBatch([]int{1, 2})
+
+	var items []int
+	q.Dequeue(&items)
+	q.Enqueue(3)
+	q.Close()
+
+	if q, err = block.NewWithStorage[int](s, codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	q.Enqueue(4)
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if len(items) != 2 || items[0] != 3 || items[1] != 4 {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+}
+
+func TestDurableQueue_Close(t *testing.T) {
+	var s = storage.NewMemory()
+
+	var q, err = block.NewWithStorage[int](s, codec.JSON[int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.EnqueueBatch([]int{1, 2, 3})
+	q.Close()
+
+	// 关闭之后剩余的元素只会在下一次打开队列时返回
+	var items []int
+	if q.Dequeue(&items); len(items) != 0 {
+		t.Fatal("关闭之后不应该再获取到元素", items)
+	}
+	if q.Enqueue(4) {
+		t.Fatal("关闭之后 Enqueue 应该返回 false")
+	}
+
+	if q, err = block.NewWithStorage[int](s, codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	q.Dequeue(&items)
+	if len(items) != 3 || items[0] != 1 || items[2] != 3 {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+}
+
+// failingStorage 第一次调用 Delete 时返回错误
+type failingStorage struct {
+	storage.Storage
+	failed bool
+}
+
+func (s *failingStorage) Delete(ids ...uint64) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("delete failed")
+	}
+	return s.Storage.Delete(ids...)
+}
+
+func TestDurableQueue_DeleteRetry(t *testing.T) {
+	var s = &failingStorage{Storage: storage.NewMemory()}
+
+	var q, err = block.NewWithStorage[int](s, codec.JSON[int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []int
+	q.Enqueue(1)
+	q.Dequeue(&items)
+	q.Enqueue(2)
+	q.Dequeue(&items)
+	q.Close()
+
+	// 第一次删除失败的元素会在下一次出队时重新删除
+	if q, err = block.NewWithStorage[int](s, codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	q.Enqueue(3)
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if len(items) != 1 || items[0] != 3 {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+}
@@ -0,0 +1,335 @@
+package block
+
+import (
+	"github.com/smartwalle/queue/observer"
+	"sync"
+	"time"
+)
+
+// Overflow 通道已满时的处理策略
+type Overflow int
+
+const (
+	// OverflowBlock 阻塞生产者，直到通道有足够的空间
+	OverflowBlock Overflow = iota
+
+	// OverflowDropNewest 丢弃新添加的元素
+	OverflowDropNewest
+
+	// OverflowDropOldest 丢弃通道中最早添加的元素
+	OverflowDropOldest
+)
+
+// WithLaneMaxSize 用于设定 lane 号通道的最大容量，只对 NewLanes 有效
+// 没有单独设定最大容量的通道使用 WithMaxSize 设定的值
+func WithLaneMaxSize(lane int, max int) Option {
+	return func(opts *options) {
+		opts.lane(lane).max = max
+	}
+}
+
+// WithLaneOverflow 用于设定 lane 号通道已满时的处理策略，默认为 OverflowBlock，只对 NewLanes 有效
+func WithLaneOverflow(lane int, overflow Overflow) Option {
+	return func(opts *options) {
+		opts.lane(lane).overflow = overflow
+	}
+}
+
+// WithLaneMinShare 用于设定每一次 Dequeue 至少从 lane 号通道获取 share 个元素（如果通道中有足够的元素），避免低优先级的通道一直无法出队，只对 NewLanes 有效
+// 只有通过 WithBatchSize 限制了每一次 Dequeue 获取的元素数量时才有意义
+func WithLaneMinShare(lane int, share int) Option {
+	return func(opts *options) {
+		opts.lane(lane).share = share
+	}
+}
+
+// WithBatchSize 用于设定每一次 Dequeue 最多获取的元素数量，小于等于 0 时不限制数量，只对 NewLanes 有效
+func WithBatchSize(size int) Option {
+	return func(opts *options) {
+		opts.batch = size
+	}
+}
+
+type laneOptions struct {
+	max      int
+	overflow Overflow
+	share    int
+}
+
+func (opts *options) lane(lane int) *laneOptions {
+	if opts.lanes == nil {
+		opts.lanes = make(map[int]*laneOptions)
+	}
+	var lOpts, ok = opts.lanes[lane]
+	if !ok {
+		lOpts = &laneOptions{max: -1}
+		opts.lanes[lane] = lOpts
+	}
+	return lOpts
+}
+
+// LaneQueue 多通道阻塞队列
+// 队列由固定数量的通道组成，通道的编号越小，其优先级越高，Dequeue 会优先获取高优先级通道中的元素
+type LaneQueue[T any] interface {
+	// Levels 获取通道的数量
+	Levels() int
+
+	// Len 获取 lane 号通道中元素的数量
+	Len(lane int) int
+
+	// Enqueue 添加元素到 lane 号通道，lane 的取值范围为 [0, Levels())，超出范围时会被调整到最近的通道
+	// 如果队列已关闭或者元素由于通道已满被丢弃，则返回 false
+	Enqueue(lane int, value T) bool
+
+	// EnqueueBatch 批量添加元素到 lane 号通道，所有元素在同一次加锁中添加
+	// 通道的处理策略为 OverflowBlock 时，会等待通道有足够的空间容纳所有元素，通道为空时不受此限制
+	// 如果队列已关闭或者有元素由于通道已满被丢弃，则返回 false
+	EnqueueBatch(lane int, values []T) bool
+
+	// Dequeue 按照通道的优先级获取队列中的元素，高优先级通道中的元素排在前面
+	// 如果队列中没有元素，则本方法会一直阻塞，直到有元素
+	// 如果队列已关闭，则返回 false，否则返回 true
+	Dequeue(*[]T) bool
+
+	// Close 关闭队列
+	Close()
+
+	// Closed 获取队列是否关闭
+	Closed() bool
+}
+
+type lane[T any] struct {
+	elements []T
+	max      int
+	overflow Overflow
+	share    int
+}
+
+type laneQueue[T any] struct {
+	options *options
+	cond    *sync.Cond
+	lanes   []*lane[T]
+	size    int
+	takes   []int
+	closed  bool
+}
+
+// NewLanes 创建拥有 levels 个通道的多通道阻塞队列，levels 小于 1 时使用 1
+// 支持的 Option 有：WithMaxSize、WithName、WithObserver 以及 WithLaneMaxSize、WithLaneOverflow、WithLaneMinShare 和 WithBatchSize
+func NewLanes[T any](levels int, opts ...Option) LaneQueue[T] {
+	if levels < 1 {
+		levels = 1
+	}
+
+	var q = &laneQueue[T]{}
+	q.options = &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q.options)
+		}
+	}
+
+	q.lanes = make([]*lane[T], levels)
+	for i := range q.lanes {
+		var l = &lane[T]{max: q.options.max}
+		if lOpts, ok := q.options.lanes[i]; ok {
+			if lOpts.max >= 0 {
+				l.max = lOpts.max
+			}
+			l.overflow = lOpts.overflow
+			l.share = lOpts.share
+		}
+		q.lanes[i] = l
+	}
+	q.takes = make([]int, levels)
+	q.cond = sync.NewCond(&sync.Mutex{})
+	return q
+}
+
+func (lq *laneQueue[T]) Levels() int {
+	return len(lq.lanes)
+}
+
+func (lq *laneQueue[T]) Len(lane int) int {
+	lq.cond.L.Lock()
+	defer lq.cond.L.Unlock()
+	return len(lq.lanes[lq.index(lane)].elements)
+}
+
+func (lq *laneQueue[T]) Enqueue(lane int, value T) bool {
+	return lq.EnqueueBatch(lane, []T{value})
+}
+
+func (lq *laneQueue[T]) EnqueueBatch(lane int, values []T) bool {
+	if len(values) == 0 {
+		return !lq.Closed()
+	}
+
+	var l = lq.lanes[lq.index(lane)]
+
+	lq.cond.L.Lock()
+	if l.overflow == OverflowBlock {
+		for !lq.closed && l.max > 0 && len(l.elements) > 0 && len(l.elements)+len(values) > l.max {
+			lq.wait(true)
+		}
+	}
+
+	if lq.closed {
+		lq.cond.L.Unlock()
+		lq.drop(len(values))
+		return false
+	}
+
+	var dropped = 0
+	if l.max > 0 && len(l.elements)+len(values) > l.max {
+		switch l.overflow {
+		case OverflowDropNewest:
+			var n = l.max - len(l.elements)
+			if n < 0 {
+				n = 0
+			}
+			dropped = len(values) - n
+			values = values[:n]
+		case OverflowDropOldest:
+			dropped = len(l.elements) + len(values) - l.max
+			if dropped >= len(l.elements) {
+				// 新添加的元素数量不小于通道的最大容量，只保留最后 max 个元素
+				values = values[len(values)-l.max:]
+				lq.size -= len(l.elements)
+				l.elements = l.elements[0:0]
+			} else {
+				var n = copy(l.elements, l.elements[dropped:])
+				l.elements = l.elements[:n]
+				lq.size -= dropped
+			}
+		}
+	}
+
+	l.elements = append(l.elements, values...)
+	lq.size += len(values)
+	if o := lq.options.observer; o != nil && len(values) > 0 {
+		o.OnEnqueue(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Size: lq.size, Count: len(values)})
+	}
+	lq.cond.L.Unlock()
+
+	if dropped > 0 {
+		lq.drop(dropped)
+	}
+	lq.cond.Broadcast()
+	return dropped == 0
+}
+
+func (lq *laneQueue[T]) Dequeue(elements *[]T) bool {
+	lq.cond.L.Lock()
+
+	for lq.size == 0 {
+		if lq.closed {
+			break
+		}
+		lq.wait(false)
+	}
+
+	var count = lq.plan()
+	for i, l := range lq.lanes {
+		var take = lq.takes[i]
+		if take == 0 {
+			continue
+		}
+		*elements = append(*elements, l.elements[:take]...)
+		var n = copy(l.elements, l.elements[take:])
+		l.elements = l.elements[:n]
+	}
+	lq.size -= count
+
+	if o := lq.options.observer; o != nil && count > 0 {
+		o.OnDequeue(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Size: lq.size, Count: count})
+	}
+
+	var closed = lq.closed
+	lq.cond.L.Unlock()
+	lq.cond.Broadcast()
+	return !closed
+}
+
+// plan 计算本次 Dequeue 从每一个通道获取的元素数量，结果保存在 takes 中，调用方需要持有锁
+// 先满足每一个通道的最小份额，剩余的数量按照通道的优先级依次分配
+func (lq *laneQueue[T]) plan() int {
+	var remain = lq.options.batch
+	if remain <= 0 {
+		remain = lq.size
+	}
+
+	var count = 0
+	for i, l := range lq.lanes {
+		var take = minInt(minInt(l.share, len(l.elements)), remain)
+		lq.takes[i] = take
+		remain -= take
+		count += take
+	}
+	for i, l := range lq.lanes {
+		var take = minInt(len(l.elements)-lq.takes[i], remain)
+		lq.takes[i] += take
+		remain -= take
+		count += take
+	}
+	return count
+}
+
+func (lq *laneQueue[T]) Close() {
+	lq.cond.L.Lock()
+	if lq.closed {
+		lq.cond.L.Unlock()
+		return
+	}
+	lq.closed = true
+	var size = lq.size
+	lq.cond.L.Unlock()
+	lq.cond.Broadcast()
+
+	if o := lq.options.observer; o != nil {
+		o.OnClose(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Size: size})
+	}
+}
+
+func (lq *laneQueue[T]) Closed() bool {
+	lq.cond.L.Lock()
+	defer lq.cond.L.Unlock()
+	return lq.closed
+}
+
+func (lq *laneQueue[T]) index(lane int) int {
+	if lane < 0 {
+		return 0
+	}
+	if lane >= len(lq.lanes) {
+		return len(lq.lanes) - 1
+	}
+	return lane
+}
+
+// wait 等待队列状态发生变化，调用方需要持有锁
+func (lq *laneQueue[T]) wait(producer bool) {
+	var o = lq.options.observer
+	if o == nil {
+		lq.cond.Wait()
+		return
+	}
+
+	o.OnBlock(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Size: lq.size, Producer: producer})
+	var start = time.Now()
+	lq.cond.Wait()
+	o.OnUnblock(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Size: lq.size, Producer: producer, Latency: time.Since(start)})
+}
+
+func (lq *laneQueue[T]) drop(n int) {
+	if o := lq.options.observer; o != nil {
+		o.OnDrop(observer.Event{Kind: observer.KindBlock, Queue: lq.options.name, Count: n, Producer: true})
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package block_test
+
+import (
+	"github.com/smartwalle/queue/block"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLaneQueue_Dequeue(t *testing.T) {
+	var q = block.NewLanes[int](3)
+
+	q.EnqueueBatch(2, []int{20, 21})
+	q.Enqueue(0, 0)
+	q.EnqueueBatch(1, []int{10, 11})
+	q.Enqueue(0, 1)
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{0, 1, 10, 11, 20, 21}) {
+		t.Fatal("Dequeue 获取到的元素顺序与预期不符", items)
+	}
+}
+
+func TestLaneQueue_MinShare(t *testing.T) {
+	var q = block.NewLanes[int](3, block.WithBatchSize(4), block.WithLaneMinShare(2, 1))
+
+	q.EnqueueBatch(0, []int{0, 1, 2, 3, 4})
+	q.EnqueueBatch(1, []int{10})
+	q.EnqueueBatch(2, []int{20, 21})
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{0, 1, 2, 20}) {
+		t.Fatal("Dequeue 没有满足通道的最小份额", items)
+	}
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{3, 4, 10, 21}) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+
+	if q.Len(0) != 0 || q.Len(1) != 0 || q.Len(2) != 0 {
+		t.Fatal("所有的元素都应该已经出队")
+	}
+}
+
+func TestLaneQueue_Overflow(t *testing.T) {
+	var q = block.NewLanes[int](3,
+		block.WithMaxSize(2),
+		block.WithLaneOverflow(0, block.OverflowDropNewest),
+		block.WithLaneOverflow(1, block.OverflowDropOldest),
+		block.WithLaneMaxSize(2, 1),
+	)
+
+	if q.EnqueueBatch(0, []int{0, 1, 2}) {
+		t.Fatal("有元素被丢弃时应该返回 false")
+	}
+	if q.EnqueueBatch(1, []int{10, 11, 12}) {
+		t.Fatal("有元素被丢弃时应该返回 false")
+	}
+	q.Enqueue(1, 13)
+	q.Enqueue(2, 20)
+
+	var done = make(chan struct{})
+	go func() {
+		// 通道已满，生产者会阻塞
+		q.Enqueue(2, 21)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("通道已满时 OverflowBlock 应该阻塞生产者")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{0, 1, 12, 13, 20}) {
+		t.Fatal("Dequeue 获取到的元素与预期不符", items)
+	}
+
+	<-done
+	if q.Len(2) != 1 {
+		t.Fatal("阻塞的生产者应该在通道有空间之后添加元素")
+	}
+
+	q.Close()
+	if q.Enqueue(0, 0) {
+		t.Fatal("队列关闭之后 Enqueue 应该返回 false")
+	}
+}
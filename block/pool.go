@@ -0,0 +1,210 @@
+package block
+
+import (
+	"context"
+	"sync"
+)
+
+type PoolOption func(opts *poolOptions)
+
+// WithPoolSize 用于设定处理元素的 goroutine 数量，默认为 1
+func WithPoolSize(n int) PoolOption {
+	return func(opts *poolOptions) {
+		if n <= 0 {
+			n = 1
+		}
+		opts.size = n
+	}
+}
+
+// WithPanicHandler 用于处理 handler 发生的 panic，未设定时 panic 会被忽略
+func WithPanicHandler(f func(v any)) PoolOption {
+	return func(opts *poolOptions) {
+		opts.onPanic = f
+	}
+}
+
+type poolOptions struct {
+	size    int
+	onPanic func(v any)
+}
+
+// Pool 基于阻塞队列的协程池
+// Pool 从阻塞队列中批量获取元素，并分发给多个 goroutine 处理
+type Pool[T any] struct {
+	queue   Queue[T]
+	handler func(values []T)
+	perItem bool
+	options *poolOptions
+	tasks   chan []T
+	start   sync.Once
+	stop    sync.Once
+	reader  chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	size    int
+	running int
+	stopped bool
+	resized chan struct{}
+}
+
+// NewPool 创建协程池，每一个元素都会单独调用一次 handler，同一批获取到的元素会分发给多个 goroutine 处理
+func NewPool[T any](queue Queue[T], handler func(value T), opts ...PoolOption) *Pool[T] {
+	var p = newPool[T](queue, func(values []T) {
+		handler(values[0])
+	}, opts...)
+	p.perItem = true
+	return p
+}
+
+// NewBatchPool 创建协程池，每一次从队列中获取到的所有元素会交由一个 goroutine 调用一次 handler 处理
+func NewBatchPool[T any](queue Queue[T], handler func(values []T), opts ...PoolOption) *Pool[T] {
+	return newPool[T](queue, handler, opts...)
+}
+
+func newPool[T any](queue Queue[T], handler func(values []T), opts ...PoolOption) *Pool[T] {
+	var p = &Pool[T]{}
+	p.queue = queue
+	p.handler = handler
+	p.options = &poolOptions{
+		size: 1,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p.options)
+		}
+	}
+	p.tasks = make(chan []T)
+	p.reader = make(chan struct{})
+	p.done = make(chan struct{})
+	p.resized = make(chan struct{})
+	return p
+}
+
+// Start 启动协程池，多次调用只有第一次有效
+func (p *Pool[T]) Start() {
+	p.start.Do(func() {
+		p.Resize(p.options.size)
+		go p.read()
+	})
+}
+
+// Size 获取协程池中 goroutine 的数量
+func (p *Pool[T]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Resize 调整协程池中 goroutine 的数量，参数 n 的值不能小于 1
+// 减少 goroutine 数量时，正在处理元素的 goroutine 会在处理完成之后退出
+// 调用 Stop 之后，本方法不会再有任何作用
+func (p *Pool[T]) Resize(n int) {
+	if n <= 0 {
+		n = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	p.size = n
+	for p.running < p.size {
+		p.running++
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	close(p.resized)
+	p.resized = make(chan struct{})
+}
+
+// Stop 关闭阻塞队列，并等待队列中剩余的元素以及正在处理的元素处理完成
+// 如果参数 ctx 先结束，则返回 ctx.Err()，剩余的元素会在后台继续处理
+func (p *Pool[T]) Stop(ctx context.Context) error {
+	p.Start()
+	p.stop.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+
+		p.queue.Close()
+		go func() {
+			<-p.reader
+			close(p.tasks)
+			p.wg.Wait()
+			close(p.done)
+		}()
+	})
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool[T]) read() {
+	defer close(p.reader)
+
+	for {
+		var values []T
+		var ok = p.queue.Dequeue(&values)
+
+		if len(values) > 0 {
+			if p.perItem {
+				for i := range values {
+					p.tasks <- values[i : i+1]
+				}
+			} else {
+				p.tasks <- values
+			}
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+func (p *Pool[T]) work() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		if p.running > p.size {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+		var resized = p.resized
+		p.mu.Unlock()
+
+		select {
+		case values, ok := <-p.tasks:
+			if !ok {
+				p.mu.Lock()
+				p.running--
+				p.mu.Unlock()
+				return
+			}
+			p.handle(values)
+		case <-resized:
+		}
+	}
+}
+
+func (p *Pool[T]) handle(values []T) {
+	defer func() {
+		if v := recover(); v != nil && p.options.onPanic != nil {
+			p.options.onPanic(v)
+		}
+	}()
+	p.handler(values)
+}
@@ -0,0 +1,163 @@
+package block_test
+
+import (
+	"context"
+	"github.com/smartwalle/queue/block"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_Item(t *testing.T) {
+	var q = block.New[int]()
+
+	var total int64
+	var p = block.NewPool[int](q, func(value int) {
+		atomic.AddInt64(&total, int64(value))
+	}, block.WithPoolSize(4))
+	p.Start()
+
+	for i := 1; i <= 1000; i++ {
+		q.Enqueue(i)
+	}
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 500500 {
+		t.Fatal("处理的元素与预期不符", total)
+	}
+}
+
+func TestPool_Batch(t *testing.T) {
+	var q = block.New[int]()
+
+	var mu sync.Mutex
+	var count int
+	var p = block.NewBatchPool[int](q, func(values []int) {
+		mu.Lock()
+		count += len(values)
+		mu.Unlock()
+	}, block.WithPoolSize(2))
+	p.Start()
+
+	for i := 0; i < 100; i++ {
+		q.EnqueueBatch([]int{i, i, i})
+	}
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 300 {
+		t.Fatal("处理的元素数量与预期不符", count)
+	}
+
+	if q.Enqueue(1) {
+		t.Fatal("协程池停止之后，队列应该被关闭")
+	}
+}
+
+func TestPool_Panic(t *testing.T) {
+	var q = block.New[int]()
+
+	var panics int32
+	var handled int32
+	var p = block.NewPool[int](q, func(value int) {
+		if value%2 == 0 {
+			panic(value)
+		}
+		atomic.AddInt32(&handled, 1)
+	}, block.WithPanicHandler(func(v any) {
+		atomic.AddInt32(&panics, 1)
+	}))
+	p.Start()
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	p.Stop(context.Background())
+
+	if panics != 5 || handled != 5 {
+		t.Fatal("panic 没有被正确处理", panics, handled)
+	}
+}
+
+func TestPool_Resize(t *testing.T) {
+	var q = block.New[int]()
+
+	var running int32
+	var max int32
+	var release = make(chan struct{})
+	var p = block.NewPool[int](q, func(value int) {
+		var n = atomic.AddInt32(&running, 1)
+		for {
+			var m = atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+	}, block.WithPoolSize(1))
+	p.Start()
+
+	p.Resize(4)
+	if p.Size() != 4 {
+		t.Fatal("Resize 之后 Size 与预期不符", p.Size())
+	}
+
+	for i := 0; i < 4; i++ {
+		q.Enqueue(i)
+	}
+
+	var deadline = time.Now().Add(time.Second)
+	for atomic.LoadInt32(&running) != 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&running) != 4 {
+		t.Fatal("Resize 之后并发处理的数量与预期不符", running)
+	}
+
+	p.Resize(1)
+	close(release)
+
+	atomic.StoreInt32(&max, 0)
+	for i := 0; i < 20; i++ {
+		q.Enqueue(i)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if max > 1 {
+		t.Fatal("缩容之后并发处理的数量与预期不符", max)
+	}
+}
+
+func TestPool_StopTimeout(t *testing.T) {
+	var q = block.New[int]()
+
+	var release = make(chan struct{})
+	var p = block.NewPool[int](q, func(value int) {
+		<-release
+	})
+	p.Start()
+	q.Enqueue(1)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := p.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatal("Stop 应该返回 context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,14 @@
 package block
 
 import (
+	"github.com/smartwalle/queue/latency"
+	"github.com/smartwalle/queue/observer"
+	"github.com/smartwalle/queue/rate"
+	"github.com/smartwalle/queue/wal"
+	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Option func(opts *options)
@@ -13,8 +19,72 @@ func WithMaxSize(max int) Option {
 	}
 }
 
+// WithAck 用于持久化队列，设定之后通过 Dequeue 获取到的元素需要调用 Ack 确认之后才会从存储中删除
+// 未确认的元素会在下一次打开队列时重新入队
+func WithAck() Option {
+	return func(opts *options) {
+		opts.ack = true
+	}
+}
+
+// WithWAL 用于设定持久化队列的预写日志参数，只对 NewDurable 有效
+func WithWAL(opts ...wal.Option) Option {
+	return func(o *options) {
+		o.wal = append(o.wal, opts...)
+	}
+}
+
+// WithName 用于设定队列名称，队列名称会出现在 Observer 接收到的事件中
+func WithName(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// WithObserver 用于设定队列的观察者
+func WithObserver(o observer.Observer) Option {
+	return func(opts *options) {
+		opts.observer = o
+	}
+}
+
+// WithLatencyTracking 用于记录每一个元素入队的时间，设定之后可以通过 DequeueEntries 获取元素在队列中等待的时间，通过 Stats 获取统计结果
+func WithLatencyTracking() Option {
+	return func(opts *options) {
+		opts.tracking = true
+	}
+}
+
+// WithRateLimit 用于限制元素出队的速度，每一个元素出队需要从 limiter 中获取一个令牌
+// Dequeue 只会获取与可用令牌数量相同的元素，剩余的元素留在队列中；没有可用的令牌时，Dequeue 会阻塞，直到有可用的令牌
+// 队列关闭之后不再限制出队速度，只对 New、NewDurable 和 NewWithStorage 有效
+func WithRateLimit(limiter *rate.Limiter) Option {
+	return func(opts *options) {
+		opts.limiter = limiter
+	}
+}
+
 type options struct {
-	max int
+	max      int
+	ack      bool
+	wal      []wal.Option
+	name     string
+	observer observer.Observer
+	tracking bool
+	lanes    map[int]*laneOptions
+	batch    int
+	limiter  *rate.Limiter
+}
+
+// Entry 队列中的元素及其入队时间
+type Entry[T any] struct {
+	Value T
+
+	// EnqueuedAt 元素入队的时间，没有设定 WithLatencyTracking 时为零值
+	EnqueuedAt time.Time
+
+	// Wait 元素在队列中等待的时间，没有设定 WithLatencyTracking 时为 0
+	Wait time.Duration
 }
 
 // Queue 阻塞队列
@@ -23,11 +93,22 @@ type Queue[T any] interface {
 	// 如果队列已关闭，则返回 false，否则返回 true
 	Enqueue(value T) bool
 
+	// EnqueueBatch 批量添加元素到队列，所有元素在同一次加锁中添加
+	// 如果设定了队列的最大容量，则会等待队列有足够的空间容纳所有元素，队列为空时不受此限制
+	// 如果队列已关闭，则返回 false，否则返回 true
+	EnqueueBatch(values []T) bool
+
 	// Dequeue 获取队列中的所有元素
 	// 如果队列中没有元素，则本方法会一直阻塞，直到有元素
 	// 如果队列已关闭，则返回 false，否则返回 true
 	Dequeue(*[]T) bool
 
+	// DequeueEntries 与 Dequeue 相同，额外返回每一个元素的入队时间及其在队列中等待的时间
+	DequeueEntries(*[]Entry[T]) bool
+
+	// Stats 获取元素在队列中等待的时间的统计结果，没有设定 WithLatencyTracking 时返回零值
+	Stats() latency.Stats
+
 	// Close 关闭队列
 	Close()
 
@@ -39,10 +120,19 @@ type blockQueue[T any] struct {
 	options  *options
 	cond     *sync.Cond
 	elements []T
+	times    []time.Time
+	recorder *latency.Recorder
 	closed   int32
+	since    time.Time
+	enqueued func(values []T) error
+	dequeued func(values []T)
 }
 
 func New[T any](opts ...Option) Queue[T] {
+	return newBlockQueue[T](opts...)
+}
+
+func newBlockQueue[T any](opts ...Option) *blockQueue[T] {
 	var q = &blockQueue[T]{}
 	q.options = &options{}
 	for _, opt := range opts {
@@ -51,18 +141,31 @@ func New[T any](opts ...Option) Queue[T] {
 		}
 	}
 	q.elements = make([]T, 0, 32)
+	if q.options.tracking {
+		q.times = make([]time.Time, 0, 32)
+		q.recorder = latency.NewRecorder(0)
+	}
 	q.cond = sync.NewCond(&sync.Mutex{})
 	return q
 }
 
 func (bq *blockQueue[T]) Enqueue(value T) bool {
 	if atomic.LoadInt32(&bq.closed) == 1 {
+		bq.drop(1)
 		return false
 	}
 
 	bq.cond.L.Lock()
 	if bq.options.max > 0 && len(bq.elements)+1 > bq.options.max {
-		bq.cond.Wait()
+		bq.wait(true)
+	}
+
+	if bq.enqueued != nil {
+		if err := bq.enqueued([]T{value}); err != nil {
+			bq.cond.L.Unlock()
+			bq.drop(1)
+			return false
+		}
 	}
 
 	n := len(bq.elements)
@@ -74,6 +177,44 @@ func (bq *blockQueue[T]) Enqueue(value T) bool {
 	}
 	bq.elements = bq.elements[0 : n+1]
 	bq.elements[n] = value
+	bq.track(1)
+	bq.observeEnqueue(1)
+
+	bq.cond.L.Unlock()
+	bq.cond.Signal()
+	return true
+}
+
+func (bq *blockQueue[T]) EnqueueBatch(values []T) bool {
+	if atomic.LoadInt32(&bq.closed) == 1 {
+		bq.drop(len(values))
+		return false
+	}
+	if len(values) == 0 {
+		return true
+	}
+
+	bq.cond.L.Lock()
+	for bq.options.max > 0 && len(bq.elements) > 0 && len(bq.elements)+len(values) > bq.options.max {
+		if atomic.LoadInt32(&bq.closed) == 1 {
+			bq.cond.L.Unlock()
+			bq.drop(len(values))
+			return false
+		}
+		bq.wait(true)
+	}
+
+	if bq.enqueued != nil {
+		if err := bq.enqueued(values); err != nil {
+			bq.cond.L.Unlock()
+			bq.drop(len(values))
+			return false
+		}
+	}
+
+	bq.elements = append(bq.elements, values...)
+	bq.track(len(values))
+	bq.observeEnqueue(len(values))
 
 	bq.cond.L.Unlock()
 	bq.cond.Signal()
@@ -81,24 +222,88 @@ func (bq *blockQueue[T]) Enqueue(value T) bool {
 }
 
 func (bq *blockQueue[T]) Dequeue(elements *[]T) bool {
+	return bq.dequeue(func(values []T, times []time.Time, now time.Time) {
+		for _, ele := range values {
+			*elements = append(*elements, ele)
+		}
+	})
+}
+
+func (bq *blockQueue[T]) DequeueEntries(entries *[]Entry[T]) bool {
+	return bq.dequeue(func(values []T, times []time.Time, now time.Time) {
+		for i, ele := range values {
+			var entry = Entry[T]{Value: ele}
+			if times != nil {
+				entry.EnqueuedAt = times[i]
+				entry.Wait = now.Sub(times[i])
+			}
+			*entries = append(*entries, entry)
+		}
+	})
+}
+
+func (bq *blockQueue[T]) Stats() latency.Stats {
+	if bq.recorder == nil {
+		return latency.Stats{}
+	}
+	return bq.recorder.Stats()
+}
+
+// dequeue 等待队列中有元素之后调用 fn 获取所有的元素，没有设定 WithLatencyTracking 时参数 times 为 nil
+func (bq *blockQueue[T]) dequeue(fn func(values []T, times []time.Time, now time.Time)) bool {
 	//if atomic.LoadInt32(&bq.closed) == 1 {
 	//	return false
 	//}
 
 	bq.cond.L.Lock()
 
-	for len(bq.elements) == 0 {
-		if atomic.LoadInt32(&bq.closed) == 1 {
+	var n int
+	for {
+		for len(bq.elements) == 0 {
+			if atomic.LoadInt32(&bq.closed) == 1 {
+				break
+			}
+			bq.wait(false)
+		}
+
+		n = len(bq.elements)
+		if bq.options.limiter == nil || n == 0 || atomic.LoadInt32(&bq.closed) == 1 {
 			break
 		}
-		bq.cond.Wait()
+		if n = bq.options.limiter.Take(n); n > 0 {
+			break
+		}
+
+		// 没有可用的令牌，等待令牌或者队列关闭
+		bq.throttle(bq.options.limiter.Delay(1))
+	}
+
+	var values = bq.elements[:n]
+	var now time.Time
+	if bq.recorder != nil {
+		now = time.Now()
+		for _, t := range bq.times[:n] {
+			bq.recorder.Record(now.Sub(t))
+		}
+		fn(values, bq.times[:n], now)
+	} else {
+		fn(values, nil, now)
 	}
 
-	for _, ele := range bq.elements {
-		*elements = append(*elements, ele)
+	if bq.dequeued != nil && n > 0 {
+		bq.dequeued(values)
 	}
 
-	bq.elements = bq.elements[0:0]
+	if o := bq.options.observer; o != nil && n > 0 {
+		o.OnDequeue(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Size: len(bq.elements) - n, Count: n, Latency: time.Since(bq.since)})
+	}
+
+	var remain = copy(bq.elements, bq.elements[n:])
+	bq.elements = bq.elements[:remain]
+	if bq.times != nil {
+		copy(bq.times, bq.times[n:])
+		bq.times = bq.times[:remain]
+	}
 	bq.cond.L.Unlock()
 	bq.cond.Signal()
 	return atomic.LoadInt32(&bq.closed) != 1
@@ -107,9 +312,75 @@ func (bq *blockQueue[T]) Dequeue(elements *[]T) bool {
 func (bq *blockQueue[T]) Close() {
 	if atomic.CompareAndSwapInt32(&bq.closed, 0, 1) {
 		bq.cond.Broadcast()
+
+		if o := bq.options.observer; o != nil {
+			bq.cond.L.Lock()
+			var size = len(bq.elements)
+			bq.cond.L.Unlock()
+			o.OnClose(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Size: size})
+		}
 	}
 }
 
 func (bq *blockQueue[T]) Closed() bool {
 	return atomic.LoadInt32(&bq.closed) == 1
 }
+
+// wait 等待队列状态发生变化，调用方需要持有锁
+func (bq *blockQueue[T]) wait(producer bool) {
+	var o = bq.options.observer
+	if o == nil {
+		bq.cond.Wait()
+		return
+	}
+
+	o.OnBlock(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Size: len(bq.elements), Producer: producer})
+	var start = time.Now()
+	bq.cond.Wait()
+	o.OnUnblock(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Size: len(bq.elements), Producer: producer, Latency: time.Since(start)})
+}
+
+// throttle 等待 d 时长之后唤醒所有等待的 goroutine，Close 也会唤醒正在等待的 goroutine，调用方需要持有锁
+// d 为 math.MaxInt64 时表示永远不会有可用的令牌，只能等待队列关闭
+func (bq *blockQueue[T]) throttle(d time.Duration) {
+	if d < math.MaxInt64 {
+		var timer = time.AfterFunc(d, func() {
+			bq.cond.L.Lock()
+			bq.cond.Broadcast()
+			bq.cond.L.Unlock()
+		})
+		defer timer.Stop()
+	}
+	bq.wait(false)
+}
+
+// track 记录最近入队的 n 个元素的入队时间，调用方需要持有锁
+func (bq *blockQueue[T]) track(n int) {
+	if bq.recorder == nil {
+		return
+	}
+	var now = time.Now()
+	for i := 0; i < n; i++ {
+		bq.times = append(bq.times, now)
+	}
+}
+
+// observeEnqueue 调用方需要持有锁
+func (bq *blockQueue[T]) observeEnqueue(n int) {
+	var o = bq.options.observer
+	if o == nil {
+		return
+	}
+
+	// 只记录当前批次中第一个元素入队的时间，用于计算出队时元素等待的时间
+	if len(bq.elements) == n {
+		bq.since = time.Now()
+	}
+	o.OnEnqueue(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Size: len(bq.elements), Count: n})
+}
+
+func (bq *blockQueue[T]) drop(n int) {
+	if o := bq.options.observer; o != nil {
+		o.OnDrop(observer.Event{Kind: observer.KindBlock, Queue: bq.options.name, Count: n, Producer: true})
+	}
+}
@@ -2,8 +2,11 @@ package block_test
 
 import (
 	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/observer"
+	"github.com/smartwalle/queue/rate"
 	"sync"
 	"testing"
+	"time"
 )
 
 func BenchmarkBlockQueue_Enqueue(b *testing.B) {
@@ -42,3 +45,148 @@ func BenchmarkBlockQueue_EnqueueDequeue(b *testing.B) {
 	wg.Wait()
 	q.Close()
 }
+
+func BenchmarkBlockQueue_EnqueueBatch(b *testing.B) {
+	var q = block.New[int]()
+
+	var values = make([]int, 64)
+	for i := 0; i < b.N; i++ {
+		q.EnqueueBatch(values)
+	}
+}
+
+func TestBlockQueue_Observer(t *testing.T) {
+	var mu sync.Mutex
+	var events = make(map[string]int)
+	var record = func(name string) func(e observer.Event) {
+		return func(e observer.Event) {
+			if e.Queue != "test" {
+				t.Error("事件中的队列名称与预期不符", e.Queue)
+			}
+			mu.Lock()
+			events[name] += e.Count
+			if e.Count == 0 {
+				events[name]++
+			}
+			mu.Unlock()
+		}
+	}
+
+	var q = block.New[int](block.WithName("test"), block.WithMaxSize(1), block.WithObserver(observer.Funcs{
+		Enqueue: record("enqueue"),
+		Dequeue: record("dequeue"),
+		Drop:    record("drop"),
+		Block:   record("block"),
+		Unblock: record("unblock"),
+		Close:   record("close"),
+	}))
+
+	q.Enqueue(1)
+
+	var done = make(chan struct{})
+	go func() {
+		// 队列已满，生产者会阻塞
+		q.Enqueue(2)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	var items []int
+	q.Dequeue(&items)
+	<-done
+	items = items[0:0]
+	q.Dequeue(&items)
+
+	q.Close()
+	q.EnqueueBatch([]int{3, 4})
+
+	mu.Lock()
+	defer mu.Unlock()
+	var expected = map[string]int{"enqueue": 2, "dequeue": 2, "drop": 2, "block": 1, "unblock": 1, "close": 1}
+	for name, count := range expected {
+		if events[name] != count {
+			t.Fatal("事件数量与预期不符", name, events[name], count)
+		}
+	}
+}
+
+func TestBlockQueue_LatencyTracking(t *testing.T) {
+	var q = block.New[int](block.WithLatencyTracking())
+	q.EnqueueBatch([]int{1, 2})
+	time.Sleep(time.Millisecond * 10)
+	q.Enqueue(3)
+
+	var entries []block.Entry[int]
+	q.DequeueEntries(&entries)
+	if len(entries) != 3 {
+		t.Fatal("DequeueEntries 获取到的元素数量与预期不符", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Value != i+1 || entry.EnqueuedAt.IsZero() {
+			t.Fatal("DequeueEntries 获取到的元素与预期不符", entry)
+		}
+	}
+	if entries[0].Wait < time.Millisecond*10 || entries[2].Wait >= entries[0].Wait {
+		t.Fatal("元素在队列中等待的时间与预期不符", entries[0].Wait, entries[2].Wait)
+	}
+
+	var stats = q.Stats()
+	if stats.Count != 3 || stats.Max != entries[0].Wait {
+		t.Fatal("Stats 与预期不符", stats)
+	}
+
+	// 没有设定 WithLatencyTracking 时不会记录入队时间
+	var nq = block.New[int]()
+	nq.Enqueue(1)
+	entries = entries[0:0]
+	nq.DequeueEntries(&entries)
+	if len(entries) != 1 || !entries[0].EnqueuedAt.IsZero() || nq.Stats().Count != 0 {
+		t.Fatal("没有设定 WithLatencyTracking 时不应该记录入队时间")
+	}
+}
+
+func TestBlockQueue_RateLimit(t *testing.T) {
+	var q = block.New[int](block.WithRateLimit(rate.NewLimiter(100, 3)))
+	q.EnqueueBatch([]int{1, 2, 3, 4, 5})
+
+	var items []int
+	q.Dequeue(&items)
+	if len(items) != 3 {
+		t.Fatal("Dequeue 获取到的元素数量应该与可用令牌数量相同", items)
+	}
+
+	// 没有可用的令牌，Dequeue 会等待令牌
+	var start = time.Now()
+	items = items[0:0]
+	q.Dequeue(&items)
+	if len(items) == 0 || items[0] != 4 || time.Since(start) < time.Millisecond*5 {
+		t.Fatal("Dequeue 应该等待令牌之后获取剩余的元素", items, time.Since(start))
+	}
+}
+
+func TestBlockQueue_RateLimitClose(t *testing.T) {
+	// limit 为 0 时永远不会有新的令牌
+	for _, limit := range []float64{0.2, 0} {
+		var q = block.New[int](block.WithRateLimit(rate.NewLimiter(limit, 1)))
+		q.EnqueueBatch([]int{1, 2})
+
+		var items []int
+		q.Dequeue(&items)
+
+		var done = make(chan struct{})
+		go func() {
+			var items []int
+			q.Dequeue(&items)
+			close(done)
+		}()
+
+		time.Sleep(time.Millisecond * 20)
+		q.Close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Close 应该唤醒正在等待令牌的 Dequeue", limit)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec 用于元素的序列化与反序列化，所有需要持久化或者导出元素的功能都使用 Codec 将元素转换为字节切片
+type Codec[T any] interface {
+	// Marshal 将元素序列化为字节切片
+	Marshal(value T) ([]byte, error)
+
+	// Unmarshal 将字节切片反序列化为元素
+	Unmarshal(data []byte) (T, error)
+}
+
+type jsonCodec[T any] struct {
+}
+
+// JSON 使用 encoding/json 序列化元素
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+func (jsonCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	var err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+type gobCodec[T any] struct {
+}
+
+// Gob 使用 encoding/gob 序列化元素
+// 如果 T 为接口类型，则需要先通过 gob.Register 注册具体的类型
+func Gob[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+func (gobCodec[T]) Marshal(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	var err = gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+type bytesCodec struct {
+}
+
+// Bytes 直接使用字节切片作为序列化结果，Unmarshal 会复制参数 data
+func Bytes() Codec[[]byte] {
+	return bytesCodec{}
+}
+
+func (bytesCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (bytesCodec) Unmarshal(data []byte) ([]byte, error) {
+	var value = make([]byte, len(data))
+	copy(value, data)
+	return value, nil
+}
+
+type stringCodec struct {
+}
+
+// String 直接使用字符串的字节作为序列化结果
+func String() Codec[string] {
+	return stringCodec{}
+}
+
+func (stringCodec) Marshal(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (stringCodec) Unmarshal(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// Marshaler 可以序列化自身的类型
+type Marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// Unmarshaler 可以反序列化自身的类型
+type Unmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+type messageCodec[E any, T interface {
+	*E
+	Marshaler
+	Unmarshaler
+}] struct {
+}
+
+// Message 使用元素自身的 Marshal 和 Unmarshal 方法序列化元素，兼容 gogo/protobuf 等工具生成的类型
+// 参数 E 为结构体类型，元素类型为 *E，例如：codec.Message[pb.Order]()
+func Message[E any, T interface {
+	*E
+	Marshaler
+	Unmarshaler
+}]() Codec[T] {
+	return messageCodec[E, T]{}
+}
+
+func (messageCodec[E, T]) Marshal(value T) ([]byte, error) {
+	return value.Marshal()
+}
+
+func (messageCodec[E, T]) Unmarshal(data []byte) (T, error) {
+	var value = T(new(E))
+	if err := value.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type funcCodec[T any] struct {
+	marshal   func(value T) ([]byte, error)
+	unmarshal func(data []byte) (T, error)
+}
+
+// Func 使用参数 marshal 和 unmarshal 序列化元素
+// 可以用于适配 google.golang.org/protobuf 的 proto.Marshal 和 proto.Unmarshal 等函数
+func Func[T any](marshal func(value T) ([]byte, error), unmarshal func(data []byte) (T, error)) Codec[T] {
+	return funcCodec[T]{marshal: marshal, unmarshal: unmarshal}
+}
+
+func (c funcCodec[T]) Marshal(value T) ([]byte, error) {
+	return c.marshal(value)
+}
+
+func (c funcCodec[T]) Unmarshal(data []byte) (T, error) {
+	return c.unmarshal(data)
+}
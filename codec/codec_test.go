@@ -0,0 +1,67 @@
+package codec_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/smartwalle/queue/codec"
+	"reflect"
+	"testing"
+)
+
+type order struct {
+	ID     string
+	Amount int64
+}
+
+// message 模拟 protobuf 生成的类型
+type message struct {
+	ID uint64
+}
+
+func (m *message) Marshal() ([]byte, error) {
+	var data = make([]byte, 8)
+	binary.BigEndian.PutUint64(data, m.ID)
+	return data, nil
+}
+
+func (m *message) Unmarshal(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("invalid data")
+	}
+	m.ID = binary.BigEndian.Uint64(data)
+	return nil
+}
+
+func testCodec[T any](t *testing.T, c codec.Codec[T], value T) {
+	var data, err = c.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nValue, err := c.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, nValue) {
+		t.Fatal("反序列化之后的元素与预期不符", value, nValue)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	testCodec(t, codec.JSON[order](), order{ID: "1", Amount: 100})
+	testCodec(t, codec.Gob[order](), order{ID: "1", Amount: 100})
+	testCodec(t, codec.Gob[int](), 10)
+	testCodec(t, codec.Bytes(), []byte("hello"))
+	testCodec(t, codec.String(), "hello")
+	testCodec(t, codec.Message[message](), &message{ID: 10})
+	testCodec(t, codec.Func(func(value int) ([]byte, error) {
+		return []byte{byte(value)}, nil
+	}, func(data []byte) (int, error) {
+		return int(data[0]), nil
+	}), 10)
+}
+
+func TestMessage_Error(t *testing.T) {
+	if _, err := codec.Message[message]().Unmarshal([]byte("1")); err == nil {
+		t.Fatal("Unmarshal 应该返回错误")
+	}
+}
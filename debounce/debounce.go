@@ -0,0 +1,188 @@
+package debounce
+
+import (
+	"github.com/smartwalle/queue/delay"
+	"sync"
+	"time"
+)
+
+type Option func(opts *options)
+
+// WithLeading 用于设定是否在一连串事件的第一个事件到达时立即触发回调，默认为 false
+func WithLeading(leading bool) Option {
+	return func(opts *options) {
+		opts.leading = leading
+	}
+}
+
+// WithTrailing 用于设定是否在一连串事件结束之后使用最后一个事件的值触发回调，默认为 true
+// 同时开启 leading 和 trailing 时，如果一连串事件中只有一个事件，则只会在 leading 触发一次
+func WithTrailing(trailing bool) Option {
+	return func(opts *options) {
+		opts.trailing = trailing
+	}
+}
+
+// WithMaxWait 用于设定一连串事件从第一个事件到达开始最多等待的时间，超过该时间之后即使还有新的事件到达也会触发回调
+// 小于等于 0 时不限制等待的时间；同时开启 leading 和 trailing 并且 maxWait 与 wait 相同时，效果相当于节流
+func WithMaxWait(maxWait time.Duration) Option {
+	return func(opts *options) {
+		opts.maxWait = maxWait
+	}
+}
+
+type options struct {
+	leading  bool
+	trailing bool
+	maxWait  time.Duration
+}
+
+// burst 同一个 key 的一连串事件
+type burst[T any] struct {
+	value   T
+	first   int64
+	pending bool
+}
+
+// Debouncer 按照 key 对事件进行防抖
+// 同一个 key 的事件到达之后会等待 wait 时长，如果在此期间有新的事件到达，则重新开始等待，并且只保留最后一个事件的值
+// 等待的超时由 delay.KeyedQueue 管理，每一个新的事件只需要更新对应 key 的过期时间
+type Debouncer[K comparable, T any] struct {
+	options *options
+	wait    time.Duration
+	fn      func(key K, value T)
+	queue   delay.KeyedQueue[K, *burst[T]]
+	mu      sync.Mutex
+	bursts  map[K]*burst[T]
+	closed  bool
+	done    chan struct{}
+}
+
+// New 创建 Debouncer，fn 为触发事件时调用的回调
+// trailing 触发的回调在 Debouncer 内部的 goroutine 中依次执行，leading 触发的回调在调用 Add 的 goroutine 中执行
+// 如果 leading 和 trailing 都没有开启，则开启 trailing
+func New[K comparable, T any](wait time.Duration, fn func(key K, value T), opts ...Option) *Debouncer[K, T] {
+	var d = &Debouncer[K, T]{}
+	d.options = &options{
+		trailing: true,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d.options)
+		}
+	}
+	if !d.options.leading && !d.options.trailing {
+		d.options.trailing = true
+	}
+
+	d.wait = wait
+	d.fn = fn
+	d.queue = delay.NewKeyed[K, *burst[T]](
+		delay.WithTimeUnit(time.Nanosecond),
+		delay.WithTimeProvider(func() int64 {
+			return time.Now().UnixNano()
+		}),
+	)
+	d.bursts = make(map[K]*burst[T])
+	d.done = make(chan struct{})
+	go d.run()
+	return d
+}
+
+func (d *Debouncer[K, T]) run() {
+	defer close(d.done)
+
+	for {
+		var key, b, expiration = d.queue.Dequeue()
+		if expiration < 0 {
+			return
+		}
+
+		d.mu.Lock()
+		if d.bursts[key] == b {
+			delete(d.bursts, key)
+		}
+		var value, fire = b.value, b.pending && d.options.trailing
+		d.mu.Unlock()
+
+		if fire {
+			d.fn(key, value)
+		}
+	}
+}
+
+// Add 添加 key 对应的事件，如果 Debouncer 已关闭，则返回 false
+func (d *Debouncer[K, T]) Add(key K, value T) bool {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return false
+	}
+
+	var now = time.Now().UnixNano()
+	if b, ok := d.bursts[key]; ok {
+		var deadline = now + int64(d.wait)
+		if d.options.maxWait > 0 && b.first+int64(d.options.maxWait) < deadline {
+			deadline = b.first + int64(d.options.maxWait)
+		}
+
+		// 如果更新失败，说明该 key 已经过期并且正在被触发，此时作为新的一连串事件处理
+		if d.queue.RescheduleByKey(key, deadline) {
+			b.value = value
+			b.pending = true
+			d.mu.Unlock()
+			return true
+		}
+	}
+
+	var b = &burst[T]{value: value, first: now, pending: !d.options.leading}
+	d.bursts[key] = b
+	d.queue.Upsert(key, b, now+int64(d.wait))
+	d.mu.Unlock()
+
+	if d.options.leading {
+		d.fn(key, value)
+	}
+	return true
+}
+
+// Cancel 取消 key 对应的等待中的事件，如果不存在等待中的事件，则返回 false
+func (d *Debouncer[K, T]) Cancel(key K) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.bursts[key]; !ok {
+		return false
+	}
+	delete(d.bursts, key)
+	d.queue.CancelByKey(key)
+	return true
+}
+
+// Close 关闭 Debouncer，所有等待中的 trailing 事件会被立即触发，Close 会等待所有的回调执行完成之后才返回
+func (d *Debouncer[K, T]) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	d.queue.Close()
+	<-d.done
+
+	d.mu.Lock()
+	var bursts = d.bursts
+	d.bursts = make(map[K]*burst[T])
+	d.mu.Unlock()
+
+	if !d.options.trailing {
+		return
+	}
+	for key, b := range bursts {
+		if b.pending {
+			d.fn(key, b.value)
+		}
+	}
+}
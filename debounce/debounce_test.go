@@ -0,0 +1,100 @@
+package debounce_test
+
+import (
+	"github.com/smartwalle/queue/debounce"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (r *recorder) fire(key string, value int) {
+	r.mu.Lock()
+	r.values = append(r.values, value)
+	r.mu.Unlock()
+}
+
+func (r *recorder) get() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.values...)
+}
+
+func TestDebouncer_Trailing(t *testing.T) {
+	var r = &recorder{}
+	var d = debounce.New[string, int](time.Millisecond*30, r.fire)
+	defer d.Close()
+
+	for i := 1; i <= 5; i++ {
+		d.Add("a", i)
+		time.Sleep(time.Millisecond * 5)
+	}
+	if values := r.get(); len(values) != 0 {
+		t.Fatal("事件还在持续到达，不应该触发回调", values)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if values := r.get(); !reflect.DeepEqual(values, []int{5}) {
+		t.Fatal("应该只使用最后一个事件的值触发一次回调", values)
+	}
+}
+
+func TestDebouncer_Leading(t *testing.T) {
+	var r = &recorder{}
+	var d = debounce.New[string, int](time.Millisecond*30, r.fire, debounce.WithLeading(true), debounce.WithTrailing(false))
+	defer d.Close()
+
+	d.Add("a", 1)
+	d.Add("a", 2)
+	d.Add("a", 3)
+	if values := r.get(); !reflect.DeepEqual(values, []int{1}) {
+		t.Fatal("第一个事件应该立即触发回调", values)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	d.Add("a", 4)
+	if values := r.get(); !reflect.DeepEqual(values, []int{1, 4}) {
+		t.Fatal("新的一连串事件应该再次触发回调", values)
+	}
+}
+
+func TestDebouncer_MaxWait(t *testing.T) {
+	var r = &recorder{}
+	var d = debounce.New[string, int](time.Millisecond*30, r.fire, debounce.WithMaxWait(time.Millisecond*50))
+	defer d.Close()
+
+	var start = time.Now()
+	for i := 1; time.Since(start) < time.Millisecond*120; i++ {
+		d.Add("a", i)
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	// 事件一直在持续到达，但是每 50 毫秒至少会触发一次回调
+	if values := r.get(); len(values) < 2 {
+		t.Fatal("超过 maxWait 之后应该触发回调", values)
+	}
+}
+
+func TestDebouncer_CancelClose(t *testing.T) {
+	var r = &recorder{}
+	var d = debounce.New[string, int](time.Hour, r.fire)
+
+	d.Add("a", 1)
+	d.Add("b", 2)
+	if !d.Cancel("a") || d.Cancel("a") {
+		t.Fatal("Cancel 的返回值与预期不符")
+	}
+
+	d.Close()
+	if values := r.get(); !reflect.DeepEqual(values, []int{2}) {
+		t.Fatal("Close 应该立即触发所有等待中的事件", values)
+	}
+	if d.Add("a", 3) {
+		t.Fatal("关闭之后 Add 应该返回 false")
+	}
+}
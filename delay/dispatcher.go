@@ -0,0 +1,132 @@
+package delay
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Handler 用于处理延迟队列中已过期的元素
+type Handler[T any] func(ctx context.Context, value T) error
+
+type DispatcherOption func(opts *dispatcherOptions)
+
+// WithWorkers 用于设定处理元素的 goroutine 数量，默认为 1
+func WithWorkers(n int) DispatcherOption {
+	return func(opts *dispatcherOptions) {
+		if n <= 0 {
+			n = 1
+		}
+		opts.workers = n
+	}
+}
+
+// WithHandlerTimeout 用于设定每一次调用 Handler 的超时时间，超时之后 Handler 的 ctx 会被取消
+func WithHandlerTimeout(timeout time.Duration) DispatcherOption {
+	return func(opts *dispatcherOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithErrorHandler 用于接收 Handler 返回的错误，Handler 发生 panic 时接收到的错误为 *PanicError
+func WithErrorHandler(f func(err error)) DispatcherOption {
+	return func(opts *dispatcherOptions) {
+		opts.onError = f
+	}
+}
+
+type dispatcherOptions struct {
+	workers int
+	timeout time.Duration
+	onError func(err error)
+}
+
+// PanicError Handler 发生 panic 时返回的错误
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("delay: handler panic: %v", e.Value)
+}
+
+// Dispatcher 从延迟队列中获取已过期的元素，并交由 Handler 处理
+type Dispatcher[T any] struct {
+	queue   Queue[T]
+	handler Handler[T]
+	options *dispatcherOptions
+	wg      sync.WaitGroup
+	start   sync.Once
+	close   sync.Once
+}
+
+func NewDispatcher[T any](queue Queue[T], handler Handler[T], opts ...DispatcherOption) *Dispatcher[T] {
+	var d = &Dispatcher[T]{}
+	d.queue = queue
+	d.handler = handler
+	d.options = &dispatcherOptions{
+		workers: 1,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d.options)
+		}
+	}
+	return d
+}
+
+// Start 启动所有的 goroutine 开始处理元素，多次调用只有第一次有效
+func (d *Dispatcher[T]) Start() {
+	d.start.Do(func() {
+		d.wg.Add(d.options.workers)
+		for i := 0; i < d.options.workers; i++ {
+			go d.run()
+		}
+	})
+}
+
+// Close 关闭延迟队列，并等待正在处理的元素处理完成
+// 如果延迟队列设定了 WithDrainAll，则会等到队列中所有的元素都处理完成之后才返回
+// 如果没有调用过 Start，Close 会先启动所有的 goroutine，否则设定了 WithDrainAll 的延迟队列没有消费者，Close 会一直阻塞
+func (d *Dispatcher[T]) Close() {
+	d.close.Do(func() {
+		d.Start()
+		d.queue.Close()
+		d.wg.Wait()
+	})
+}
+
+func (d *Dispatcher[T]) run() {
+	defer d.wg.Done()
+
+	for {
+		var value, expiration = d.queue.Dequeue()
+		if expiration == -1 {
+			return
+		}
+
+		if err := d.handle(value); err != nil && d.options.onError != nil {
+			d.options.onError(err)
+		}
+	}
+}
+
+func (d *Dispatcher[T]) handle(value T) (err error) {
+	var ctx = context.Background()
+	if d.options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.options.timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	return d.handler(ctx, value)
+}
@@ -0,0 +1,132 @@
+package delay_test
+
+import (
+	"context"
+	"errors"
+	"github.com/smartwalle/queue/delay"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_DrainAll(t *testing.T) {
+	var q = newMillisecondQueue[int](delay.WithDrainAll())
+
+	var handled int32
+	var d = delay.NewDispatcher[int](q, func(ctx context.Context, value int) error {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, delay.WithWorkers(4))
+	d.Start()
+
+	var now = time.Now().UnixMilli()
+	for i := 0; i < 100; i++ {
+		q.Enqueue(i, now+int64(i%20))
+	}
+
+	// DrainAll：Close 会等待所有的元素都处理完成
+	d.Close()
+
+	if n := atomic.LoadInt32(&handled); n != 100 {
+		t.Fatal("处理的元素数量与预期不符", n)
+	}
+}
+
+func TestDispatcher_CloseWithoutStart(t *testing.T) {
+	var q = newMillisecondQueue[int](delay.WithDrainAll())
+
+	var handled int32
+	var d = delay.NewDispatcher[int](q, func(ctx context.Context, value int) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	var now = time.Now().UnixMilli()
+	q.Enqueue(1, now)
+	q.Enqueue(2, now+10)
+
+	// 没有调用 Start，Close 也需要处理完所有的元素之后返回
+	var done = make(chan struct{})
+	go func() {
+		d.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("没有调用 Start 时 Close 不应该一直阻塞")
+	}
+
+	if n := atomic.LoadInt32(&handled); n != 2 {
+		t.Fatal("处理的元素数量与预期不符", n)
+	}
+}
+
+func TestDispatcher_Error(t *testing.T) {
+	var q = newMillisecondQueue[int](delay.WithDrainAll())
+
+	var mu sync.Mutex
+	var errs []error
+	var d = delay.NewDispatcher[int](q, func(ctx context.Context, value int) error {
+		switch value {
+		case 1:
+			panic("boom")
+		case 2:
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, delay.WithWorkers(2), delay.WithHandlerTimeout(time.Millisecond*10), delay.WithErrorHandler(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+	d.Start()
+
+	var now = time.Now().UnixMilli()
+	q.Enqueue(1, now)
+	q.Enqueue(2, now)
+	q.Enqueue(3, now)
+
+	d.Close()
+
+	var panicErr *delay.PanicError
+	var timeout bool
+	for _, err := range errs {
+		if errors.Is(err, context.DeadlineExceeded) {
+			timeout = true
+		}
+		if errors.As(err, &panicErr) && panicErr.Value != "boom" {
+			t.Fatal("PanicError 与预期不符", panicErr.Value)
+		}
+	}
+	if len(errs) != 2 || panicErr == nil || !timeout {
+		t.Fatal("错误信息与预期不符", errs)
+	}
+}
+
+func TestDispatcher_CloseWaitsInFlight(t *testing.T) {
+	var q = newMillisecondQueue[int]()
+
+	var started = make(chan struct{})
+	var finished int32
+	var d = delay.NewDispatcher[int](q, func(ctx context.Context, value int) error {
+		close(started)
+		time.Sleep(time.Millisecond * 50)
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	})
+	d.Start()
+
+	q.Enqueue(1, time.Now().UnixMilli())
+	<-started
+
+	d.Close()
+
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatal("Close 应该等待正在处理的元素处理完成")
+	}
+}
@@ -0,0 +1,303 @@
+package delay
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/priority"
+	"github.com/smartwalle/queue/storage"
+	"sync"
+)
+
+var ErrInvalidRecord = errors.New("delay: invalid record")
+
+const recordHeaderSize = 8
+
+type durableItem[T any] struct {
+	id    uint64
+	value T
+}
+
+type durableEntry struct {
+	ele  priority.Element
+	data []byte
+}
+
+type durableQueue[T any] struct {
+	dq      *delayQueue[durableItem[T]]
+	codec   codec.Codec[T]
+	storage storage.Storage
+	nextID  uint64
+	entries map[uint64]*durableEntry
+	ids     map[priority.Element]uint64
+	deletes []uint64
+	close   sync.Once
+}
+
+// NewDurable 打开 dir 目录中的持久化延迟队列，如果目录不存在则创建，元素存储在本地文件中，参考 storage.NewFile
+// Enqueue、Update、Remove 以及元素出队都会写入预写日志，存储会定期生成快照并截断日志
+// 重新打开队列时会从快照和日志中恢复所有未出队的元素，在进程退出期间已过期的元素会立即出队
+func NewDurable[T any](dir string, c codec.Codec[T], opts ...Option) (Queue[T], error) {
+	var dq = newDelayQueue[durableItem[T]](opts...)
+	var s, err = storage.NewFile(dir, dq.options.file...)
+	if err != nil {
+		return nil, err
+	}
+	return newDurableQueue[T](dq, s, c)
+}
+
+// NewWithStorage 使用存储 s 创建持久化延迟队列，并从存储中恢复所有的元素
+// 调用 Close 时会关闭存储
+func NewWithStorage[T any](s storage.Storage, c codec.Codec[T], opts ...Option) (Queue[T], error) {
+	return newDurableQueue[T](newDelayQueue[durableItem[T]](opts...), s, c)
+}
+
+func newDurableQueue[T any](dq *delayQueue[durableItem[T]], s storage.Storage, c codec.Codec[T]) (Queue[T], error) {
+	var q = &durableQueue[T]{}
+	q.dq = dq
+	q.codec = c
+	q.storage = s
+	q.nextID = 1
+	q.entries = make(map[uint64]*durableEntry)
+	q.ids = make(map[priority.Element]uint64)
+	q.dq.dequeued = q.onDequeue
+
+	var values []durableItem[T]
+	var expirations []int64
+	if err := s.Load(func(record storage.Record) error {
+		if len(record.Data) < recordHeaderSize {
+			return ErrInvalidRecord
+		}
+		var data = record.Data[recordHeaderSize:]
+		var value, err = c.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+		values = append(values, durableItem[T]{id: record.ID, value: value})
+		expirations = append(expirations, int64(binary.BigEndian.Uint64(record.Data)))
+		q.entries[record.ID] = &durableEntry{data: data}
+		q.nextID = record.ID + 1
+		return nil
+	}); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	var eles = q.dq.pushBatch(values, expirations)
+	for i, ele := range eles {
+		q.entries[values[i].id].ele = ele
+		q.ids[ele] = values[i].id
+	}
+	return q, nil
+}
+
+func (q *durableQueue[T]) Len() int {
+	return q.dq.Len()
+}
+
+func (q *durableQueue[T]) Enqueue(value T, expiration int64) priority.Element {
+	var eles = q.EnqueueBatch([]T{value}, []int64{expiration})
+	if len(eles) == 0 {
+		return nil
+	}
+	return eles[0]
+}
+
+func (q *durableQueue[T]) EnqueueBatch(values []T, expirations []int64) []priority.Element {
+	if len(values) != len(expirations) {
+		return nil
+	}
+
+	var records = make([]storage.Record, len(values))
+	for i, value := range values {
+		var data, err = q.codec.Marshal(value)
+		if err != nil {
+			q.dq.drop(len(values))
+			return nil
+		}
+		records[i].Data = encodeRecord(expirations[i], data)
+	}
+
+	q.dq.mu.Lock()
+	if q.dq.closed {
+		q.dq.mu.Unlock()
+		q.dq.drop(len(values))
+		return nil
+	}
+
+	var items = make([]durableItem[T], len(values))
+	for i, value := range values {
+		items[i] = durableItem[T]{id: q.nextID + uint64(i), value: value}
+		records[i].ID = items[i].id
+	}
+
+	if err := q.storage.Put(records...); err != nil {
+		q.dq.mu.Unlock()
+		q.dq.drop(len(values))
+		return nil
+	}
+	q.nextID += uint64(len(values))
+
+	var eles []priority.Element
+	if len(items) == 1 {
+		eles = []priority.Element{q.dq.push(items[0], expirations[0])}
+	} else {
+		eles = q.dq.pushBatch(items, expirations)
+	}
+
+	var first = false
+	for i, ele := range eles {
+		q.entries[items[i].id] = &durableEntry{ele: ele, data: records[i].Data[recordHeaderSize:]}
+		q.ids[ele] = items[i].id
+		first = first || ele.First()
+	}
+	q.dq.observeEnqueue(len(eles))
+	q.dq.mu.Unlock()
+
+	if first {
+		q.dq.notify()
+	}
+	return eles
+}
+
+func (q *durableQueue[T]) Dequeue() (T, int64) {
+	var item, expiration = q.dq.Dequeue()
+	return item.value, expiration
+}
+
+func (q *durableQueue[T]) DequeueItem() (Item[T], bool) {
+	var item, ok = q.dq.DequeueItem()
+	return unwrapItem(item), ok
+}
+
+func (q *durableQueue[T]) DequeueBatch(max int) []Item[T] {
+	var items = q.dq.DequeueBatch(max)
+	if items == nil {
+		return nil
+	}
+
+	var nItems = make([]Item[T], len(items))
+	for i, item := range items {
+		nItems[i] = unwrapItem(item)
+	}
+	return nItems
+}
+
+func (q *durableQueue[T]) Stats() Stats {
+	return q.dq.Stats()
+}
+
+func (q *durableQueue[T]) Update(ele priority.Element, expiration int64) {
+	q.dq.mu.Lock()
+	if q.dq.closed {
+		q.dq.mu.Unlock()
+		return
+	}
+
+	var id, ok = q.ids[ele]
+	if !ok {
+		q.dq.mu.Unlock()
+		return
+	}
+
+	var record = storage.Record{ID: id, Data: encodeRecord(expiration, q.entries[id].data)}
+	if err := q.storage.Put(record); err != nil {
+		q.dq.mu.Unlock()
+		return
+	}
+
+	var first = ele.First()
+	q.dq.pq.Update(ele, expiration)
+	first = first || ele.First()
+	q.dq.mu.Unlock()
+
+	if first {
+		q.dq.notify()
+	}
+}
+
+func (q *durableQueue[T]) Remove(ele priority.Element) {
+	q.dq.mu.Lock()
+	if q.dq.closed {
+		q.dq.mu.Unlock()
+		return
+	}
+
+	var id, ok = q.ids[ele]
+	if !ok {
+		q.dq.mu.Unlock()
+		return
+	}
+
+	if err := q.storage.Delete(id); err != nil {
+		q.dq.mu.Unlock()
+		return
+	}
+	delete(q.entries, id)
+	delete(q.ids, ele)
+
+	var first = ele.First()
+	q.dq.remove(ele)
+	q.dq.mu.Unlock()
+
+	if first {
+		q.dq.notify()
+	}
+}
+
+// onDequeue 元素出队之后将其从存储中删除，调用方持有锁
+func (q *durableQueue[T]) onDequeue(item durableItem[T]) {
+	q.deletes = append(q.deletes, item.id)
+	q.flush()
+	if entry, ok := q.entries[item.id]; ok {
+		delete(q.ids, entry.ele)
+		delete(q.entries, item.id)
+	}
+}
+
+// flush 从存储中删除已出队的元素，删除失败时保留这些元素的 ID，下一次出队或者关闭队列时会再次删除，调用方需要持有锁
+func (q *durableQueue[T]) flush() error {
+	if len(q.deletes) == 0 {
+		return nil
+	}
+	if err := q.storage.Delete(q.deletes...); err != nil {
+		return err
+	}
+	q.deletes = q.deletes[:0]
+	return nil
+}
+
+// Close 关闭队列及其存储
+// 如果设定了 WithDrainAll，则会等到所有的元素都出队之后才关闭存储
+func (q *durableQueue[T]) Close() {
+	q.close.Do(func() {
+		q.dq.Close()
+
+		q.dq.mu.Lock()
+		q.flush()
+		q.dq.mu.Unlock()
+
+		q.storage.Close()
+	})
+}
+
+func (q *durableQueue[T]) Closed() bool {
+	return q.dq.Closed()
+}
+
+func unwrapItem[T any](item Item[durableItem[T]]) Item[T] {
+	return Item[T]{
+		Value:      item.Value.value,
+		Expiration: item.Expiration,
+		EnqueuedAt: item.EnqueuedAt,
+		Wait:       item.Wait,
+		Lateness:   item.Lateness,
+	}
+}
+
+func encodeRecord(expiration int64, data []byte) []byte {
+	var record = make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint64(record, uint64(expiration))
+	copy(record[recordHeaderSize:], data)
+	return record
+}
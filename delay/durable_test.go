@@ -0,0 +1,177 @@
+package delay_test
+
+import (
+	"errors"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/delay"
+	"github.com/smartwalle/queue/storage"
+	"github.com/smartwalle/queue/wal"
+	"testing"
+	"time"
+)
+
+func newDurableQueue(t *testing.T, dir string, opts ...delay.Option) delay.Queue[string] {
+	var q, err = delay.NewDurable[string](dir, codec.JSON[string](), millisecond(opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func BenchmarkDurableQueue_Enqueue(b *testing.B) {
+	var q, err = delay.NewDurable[int](b.TempDir(), codec.JSON[int](), delay.WithWAL(wal.WithSyncPolicy(wal.SyncNever)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer q.Close()
+
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(i, int64(i))
+	}
+}
+
+func TestDurableQueue_Snapshot(t *testing.T) {
+	var dir = t.TempDir()
+
+	var q = newDurableQueue(t, dir)
+	var now = time.Now().UnixMilli()
+	q.Enqueue("1", now)
+	var ele2 = q.Enqueue("2", now+100000)
+	var ele3 = q.Enqueue("3", now+100000)
+	q.EnqueueBatch([]string{"4", "5"}, []int64{now + 200000, now + 300000})
+
+	if value, _ := q.Dequeue(); value != "1" {
+		t.Fatal("Dequeue 获取到的元素与预期不符", value)
+	}
+	q.Update(ele2, now+50)
+	q.Remove(ele3)
+
+	// Close 会生成快照
+	q.Close()
+
+	q = newDurableQueue(t, dir)
+	defer q.Close()
+
+	if q.Len() != 3 {
+		t.Fatal("恢复的元素数量与预期不符", q.Len())
+	}
+	if value, expiration := q.Dequeue(); value != "2" || expiration != now+50 {
+		t.Fatal("恢复的元素与预期不符", value, expiration)
+	}
+}
+
+func TestDurableQueue_Crash(t *testing.T) {
+	var dir = t.TempDir()
+
+	// 不生成快照，也不调用 Close，模拟进程异常退出，只能从日志中恢复
+	var q = newDurableQueue(t, dir, delay.WithSnapshotInterval(0))
+	var now = time.Now().UnixMilli()
+	var ele = q.Enqueue("1", now+100000)
+	q.Enqueue("2", now+30)
+	q.Enqueue("3", now+40)
+	q.Update(ele, now+20)
+	q.Enqueue("4", now+100000)
+
+	// 进程退出期间元素已过期，恢复之后会立即出队
+	time.Sleep(time.Millisecond * 60)
+
+	q = newDurableQueue(t, dir)
+	defer q.Close()
+
+	var items = q.DequeueBatch(0)
+	if len(items) != 3 || items[0].Value != "1" || items[1].Value != "2" || items[2].Value != "3" {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+
+	// 新的元素不能与恢复的元素冲突
+	q.Enqueue("5", now)
+	if value, _ := q.Dequeue(); value != "5" {
+		t.Fatal("Dequeue 获取到的元素与预期不符", value)
+	}
+	if q.Len() != 1 {
+		t.Fatal("队列中元素的数量与预期不符", q.Len())
+	}
+}
+
+func TestDurableQueue_PeriodicSnapshot(t *testing.T) {
+	var dir = t.TempDir()
+
+	var q = newDurableQueue(t, dir, delay.WithSnapshotInterval(time.Millisecond*10))
+	var now = time.Now().UnixMilli()
+	q.Enqueue("1", now+100000)
+	q.Enqueue("2", now)
+	q.Dequeue()
+
+	time.Sleep(time.Millisecond * 50)
+	q.Enqueue("3", now+100000)
+
+	q = newDurableQueue(t, dir)
+	defer q.Close()
+
+	if q.Len() != 2 {
+		t.Fatal("恢复的元素数量与预期不符", q.Len())
+	}
+}
+
+func TestDurableQueue_Storage(t *testing.T) {
+	var s = storage.NewMemory()
+
+	var q, err = delay.NewWithStorage[string](s, codec.JSON[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now = time.Now().Unix()
+	var ele = q.Enqueue("1", now+1000)
+	q.Enqueue("2", now+1000)
+	q.Update(ele, now)
+	q.Close()
+
+	if q, err = delay.NewWithStorage[string](s, codec.JSON[string]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if value, expiration := q.Dequeue(); value != "1" || expiration != now {
+		t.Fatal("恢复的元素与预期不符", value, expiration)
+	}
+	if q.Len() != 1 {
+		t.Fatal("恢复的元素数量与预期不符", q.Len())
+	}
+}
+
+// failingStorage 第一次调用 Delete 时返回错误
+type failingStorage struct {
+	storage.Storage
+	failed bool
+}
+
+func (s *failingStorage) Delete(ids ...uint64) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("delete failed")
+	}
+	return s.Storage.Delete(ids...)
+}
+
+func TestDurableQueue_DeleteRetry(t *testing.T) {
+	var s = &failingStorage{Storage: storage.NewMemory()}
+
+	var q, err = delay.NewWithStorage[string](s, codec.JSON[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now = time.Now().Unix()
+	q.Enqueue("1", now)
+	q.Dequeue()
+	q.Close()
+
+	// 出队时删除失败的元素会在关闭队列时再次删除
+	if q, err = delay.NewWithStorage[string](s, codec.JSON[string]()); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if q.Len() != 0 {
+		t.Fatal("已出队的元素不应该被恢复", q.Len())
+	}
+}
@@ -0,0 +1,183 @@
+package delay
+
+import (
+	"github.com/smartwalle/queue/priority"
+)
+
+// KeyedQueue 带 key 的延迟队列
+// 队列中每一个元素都有一个唯一的 key，去重、重新调度和取消操作都在队列内部的锁中完成
+type KeyedQueue[K comparable, T any] interface {
+	// Len 获取队列元素数量
+	Len() int
+
+	// Enqueue 添加元素到队列
+	// 参数 expiration 的值不能小于 0
+	// 如果队列中已经存在相同 key 的元素，则不会做任何修改，返回已存在的元素和 false
+	// 如果队列已关闭，则返回 nil 和 false
+	Enqueue(key K, value T, expiration int64) (priority.Element, bool)
+
+	// Upsert 添加元素到队列
+	// 参数 expiration 的值不能小于 0
+	// 如果队列中已经存在相同 key 的元素，则使用新的值及过期时间替换该元素
+	// 如果队列已关闭，则返回 nil
+	Upsert(key K, value T, expiration int64) priority.Element
+
+	// Dequeue 获取队列中已过期的元素的 key、值及其过期时间，并且将该元素从队列中删除
+	// 如果队列中没有过期的元素，则本方法会一直阻塞，直到有过期的元素
+	// 如果队列被关闭，则返回空值和 -1
+	Dequeue() (K, T, int64)
+
+	// RescheduleByKey 更新 key 对应的元素的过期时间
+	// 如果队列中不存在该 key 或者队列已关闭，则返回 false
+	RescheduleByKey(key K, expiration int64) bool
+
+	// CancelByKey 从队列中删除 key 对应的元素
+	// 如果队列中不存在该 key 或者队列已关闭，则返回 false
+	CancelByKey(key K) bool
+
+	// Close 关闭队列
+	Close()
+
+	// Closed 获取队列是否关闭
+	Closed() bool
+}
+
+type keyedItem[K comparable, T any] struct {
+	key   K
+	value T
+}
+
+type keyedQueue[K comparable, T any] struct {
+	dq   *delayQueue[keyedItem[K, T]]
+	keys map[K]priority.Element
+}
+
+func NewKeyed[K comparable, T any](opts ...Option) KeyedQueue[K, T] {
+	var q = &keyedQueue[K, T]{}
+	q.keys = make(map[K]priority.Element)
+	q.dq = newDelayQueue[keyedItem[K, T]](opts...)
+	q.dq.dequeued = func(item keyedItem[K, T]) {
+		delete(q.keys, item.key)
+	}
+	return q
+}
+
+func (kq *keyedQueue[K, T]) Len() int {
+	return kq.dq.Len()
+}
+
+func (kq *keyedQueue[K, T]) Enqueue(key K, value T, expiration int64) (priority.Element, bool) {
+	kq.dq.mu.Lock()
+	if kq.dq.closed {
+		kq.dq.mu.Unlock()
+		kq.dq.drop(1)
+		return nil, false
+	}
+
+	if ele, ok := kq.keys[key]; ok {
+		kq.dq.mu.Unlock()
+		return ele, false
+	}
+
+	var ele = kq.dq.push(keyedItem[K, T]{key: key, value: value}, expiration)
+	kq.keys[key] = ele
+	var first = ele.First()
+	kq.dq.observeEnqueue(1)
+	kq.dq.mu.Unlock()
+
+	if first {
+		kq.dq.notify()
+	}
+	return ele, true
+}
+
+func (kq *keyedQueue[K, T]) Upsert(key K, value T, expiration int64) priority.Element {
+	kq.dq.mu.Lock()
+	if kq.dq.closed {
+		kq.dq.mu.Unlock()
+		kq.dq.drop(1)
+		return nil
+	}
+
+	// 替换已存在的元素时队列中元素的数量不变，只有新的 key 才会触发 OnEnqueue
+	var first bool
+	var old, replace = kq.keys[key]
+	if replace {
+		first = old.First()
+		kq.dq.pq.Remove(old)
+	}
+
+	var ele = kq.dq.push(keyedItem[K, T]{key: key, value: value}, expiration)
+	kq.keys[key] = ele
+	first = first || ele.First()
+	if !replace {
+		kq.dq.observeEnqueue(1)
+	}
+	kq.dq.mu.Unlock()
+
+	if first {
+		kq.dq.notify()
+	}
+	return ele
+}
+
+func (kq *keyedQueue[K, T]) Dequeue() (K, T, int64) {
+	var item, expiration = kq.dq.Dequeue()
+	return item.key, item.value, expiration
+}
+
+func (kq *keyedQueue[K, T]) RescheduleByKey(key K, expiration int64) bool {
+	kq.dq.mu.Lock()
+	if kq.dq.closed {
+		kq.dq.mu.Unlock()
+		return false
+	}
+
+	var ele, ok = kq.keys[key]
+	if !ok {
+		kq.dq.mu.Unlock()
+		return false
+	}
+
+	var first = ele.First()
+	kq.dq.pq.Update(ele, expiration)
+	first = first || ele.First()
+	kq.dq.mu.Unlock()
+
+	if first {
+		kq.dq.notify()
+	}
+	return true
+}
+
+func (kq *keyedQueue[K, T]) CancelByKey(key K) bool {
+	kq.dq.mu.Lock()
+	if kq.dq.closed {
+		kq.dq.mu.Unlock()
+		return false
+	}
+
+	var ele, ok = kq.keys[key]
+	if !ok {
+		kq.dq.mu.Unlock()
+		return false
+	}
+
+	var first = ele.First()
+	delete(kq.keys, key)
+	kq.dq.remove(ele)
+	kq.dq.mu.Unlock()
+
+	if first {
+		kq.dq.notify()
+	}
+	return true
+}
+
+func (kq *keyedQueue[K, T]) Close() {
+	kq.dq.Close()
+}
+
+func (kq *keyedQueue[K, T]) Closed() bool {
+	return kq.dq.Closed()
+}
@@ -0,0 +1,101 @@
+package delay_test
+
+import (
+	"github.com/smartwalle/queue/delay"
+	"testing"
+	"time"
+)
+
+func newKeyedQueue[K comparable, T any](opts ...delay.Option) delay.KeyedQueue[K, T] {
+	return delay.NewKeyed[K, T](millisecond(opts...)...)
+}
+
+func TestKeyedQueue_Enqueue(t *testing.T) {
+	var q = newKeyedQueue[string, int]()
+	defer q.Close()
+
+	var now = time.Now().UnixMilli()
+	if _, ok := q.Enqueue("order-1", 1, now+20); !ok {
+		t.Fatal("Enqueue 新的 key 应该成功")
+	}
+	if _, ok := q.Enqueue("order-1", 2, now); ok {
+		t.Fatal("Enqueue 已存在的 key 应该失败")
+	}
+
+	if q.Len() != 1 {
+		t.Fatal("Enqueue 已存在的 key 不应该新增元素")
+	}
+
+	if key, value, _ := q.Dequeue(); key != "order-1" || value != 1 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", key, value)
+	}
+
+	if _, ok := q.Enqueue("order-1", 3, now); !ok {
+		t.Fatal("元素出队之后，再次 Enqueue 相同的 key 应该成功")
+	}
+}
+
+func TestKeyedQueue_Upsert(t *testing.T) {
+	var q = newKeyedQueue[string, int]()
+	defer q.Close()
+
+	var now = time.Now().UnixMilli()
+	q.Upsert("order-1", 1, now+10000)
+	q.Upsert("order-2", 2, now+50)
+	q.Upsert("order-1", 3, now)
+
+	if q.Len() != 2 {
+		t.Fatal("Upsert 已存在的 key 不应该新增元素")
+	}
+
+	if key, value, _ := q.Dequeue(); key != "order-1" || value != 3 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", key, value)
+	}
+	if key, value, _ := q.Dequeue(); key != "order-2" || value != 2 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", key, value)
+	}
+}
+
+func TestKeyedQueue_RescheduleAndCancel(t *testing.T) {
+	var q = newKeyedQueue[string, int]()
+
+	var now = time.Now().UnixMilli()
+	q.Enqueue("order-1", 1, now+10000)
+	q.Enqueue("order-2", 2, now+10000)
+	q.Enqueue("order-3", 3, now+10000)
+
+	var done = make(chan string)
+	go func() {
+		var key, _, _ = q.Dequeue()
+		done <- key
+	}()
+
+	// Dequeue 正在等待 order-1 过期，取消和重新调度都需要唤醒 Dequeue
+	time.Sleep(time.Millisecond * 10)
+	if !q.CancelByKey("order-1") {
+		t.Fatal("CancelByKey 应该成功")
+	}
+	if q.CancelByKey("order-1") {
+		t.Fatal("CancelByKey 已删除的 key 应该失败")
+	}
+	if !q.RescheduleByKey("order-3", time.Now().UnixMilli()+20) {
+		t.Fatal("RescheduleByKey 应该成功")
+	}
+	if q.RescheduleByKey("order-4", now) {
+		t.Fatal("RescheduleByKey 不存在的 key 应该失败")
+	}
+
+	select {
+	case key := <-done:
+		if key != "order-3" {
+			t.Fatal("Dequeue 获取到的元素与预期不符", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RescheduleByKey 之后 Dequeue 没有被唤醒")
+	}
+
+	q.Close()
+	if q.CancelByKey("order-2") {
+		t.Fatal("队列已关闭，CancelByKey 应该失败")
+	}
+}
@@ -1,9 +1,13 @@
 package delay
 
 import (
+	"github.com/smartwalle/queue/latency"
+	"github.com/smartwalle/queue/observer"
 	"github.com/smartwalle/queue/priority"
+	"github.com/smartwalle/queue/rate"
+	"github.com/smartwalle/queue/storage"
+	"github.com/smartwalle/queue/wal"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -38,13 +42,87 @@ func WithDrainAll() Option {
 	}
 }
 
+// WithSnapshotInterval 用于设定持久化队列生成快照的时间间隔，默认为 1 分钟，小于等于 0 时只在关闭队列时生成快照，只对 NewDurable 有效
+func WithSnapshotInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.file = append(opts.file, storage.WithSnapshotInterval(interval))
+	}
+}
+
+// WithWAL 用于设定持久化队列的预写日志参数，只对 NewDurable 有效
+func WithWAL(opts ...wal.Option) Option {
+	return func(o *options) {
+		o.file = append(o.file, storage.WithWAL(opts...))
+	}
+}
+
+// WithName 用于设定队列名称，队列名称会出现在 Observer 接收到的事件中
+func WithName(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// WithObserver 用于设定队列的观察者
+func WithObserver(o observer.Observer) Option {
+	return func(opts *options) {
+		opts.observer = o
+	}
+}
+
+// WithLatencyTracking 用于记录每一个元素入队的时间，设定之后可以通过 DequeueItem 和 DequeueBatch 获取元素在队列中等待的时间，通过 Stats 获取统计结果
+func WithLatencyTracking() Option {
+	return func(opts *options) {
+		opts.tracking = true
+	}
+}
+
+// WithRateLimit 用于限制元素出队的速度，每一个元素出队需要从 limiter 中获取一个令牌
+// 没有可用的令牌时，Dequeue 会阻塞，直到有可用的令牌；DequeueBatch 只会获取与可用令牌数量相同的元素
+// 队列关闭之后不再限制出队速度
+func WithRateLimit(limiter *rate.Limiter) Option {
+	return func(opts *options) {
+		opts.limiter = limiter
+	}
+}
+
 type options struct {
 	clock    func() int64
 	unit     time.Duration
 	drainAll bool
+	file     []storage.FileOption
+	name     string
+	observer observer.Observer
+	tracking bool
+	limiter  *rate.Limiter
+}
+
+// Item 延迟队列中的元素及其过期时间
+type Item[T any] struct {
+	Value      T
+	Expiration int64
+
+	// EnqueuedAt 元素入队的时间，没有设定 WithLatencyTracking 时为零值
+	EnqueuedAt time.Time
+
+	// Wait 元素在队列中等待的时间，没有设定 WithLatencyTracking 时为 0
+	Wait time.Duration
+
+	// Lateness 元素出队的时间与其过期时间的差值
+	Lateness time.Duration
+}
+
+// Stats 延迟队列的耗时统计
+type Stats struct {
+	// Wait 元素在队列中等待的时间
+	Wait latency.Stats
+
+	// Lateness 元素出队的时间与其过期时间的差值
+	Lateness latency.Stats
 }
 
 // Queue 延迟队列
+// 支持多个 goroutine 同时调用 Dequeue 和 DequeueBatch，每当有元素过期，只会唤醒一个等待中的调用方
 type Queue[T any] interface {
 	// Len 获取队列元素数量
 	Len() int
@@ -54,11 +132,25 @@ type Queue[T any] interface {
 	// 如果队列已关闭，则返回 nil
 	Enqueue(value T, expiration int64) priority.Element
 
+	// EnqueueBatch 批量添加元素到队列，参数 values 和 expirations 的元素一一对应
+	// 如果队列已关闭或者参数 values 和 expirations 的长度不一致，则返回 nil
+	EnqueueBatch(values []T, expirations []int64) []priority.Element
+
 	// Dequeue 获取队列中已过期的元素及其过期时间，并且将该元素从队列中删除
 	// 如果队列中没有过期的元素，则本方法会一直阻塞，直到有过期的元素
 	// 如果队列被关闭，则返回空值和 -1
 	Dequeue() (T, int64)
 
+	// DequeueItem 与 Dequeue 相同，额外返回元素的入队时间、在队列中等待的时间以及出队的时间与其过期时间的差值
+	// 如果队列被关闭，则返回 false
+	DequeueItem() (Item[T], bool)
+
+	// DequeueBatch 获取队列中所有已过期的元素及其过期时间，并且将这些元素从队列中删除，最多获取 max 个元素，max 小于等于 0 时不限制数量
+	// 所有元素在同一次加锁中获取
+	// 如果队列中没有过期的元素，则本方法会一直阻塞，直到有过期的元素
+	// 如果队列被关闭，则返回 nil
+	DequeueBatch(max int) []Item[T]
+
 	// Update 更新元素的过期时间
 	Update(ele priority.Element, expiration int64)
 
@@ -70,21 +162,37 @@ type Queue[T any] interface {
 
 	// Closed 获取队列是否关闭
 	Closed() bool
+
+	// Stats 获取元素在队列中等待的时间以及出队延迟的统计结果，没有设定 WithLatencyTracking 时返回零值
+	Stats() Stats
+}
+
+// entry 队列中的元素，没有设定 WithLatencyTracking 时 enqueued 为 0
+type entry[T any] struct {
+	value    T
+	enqueued int64
 }
 
 type delayQueue[T any] struct {
-	pq       priority.Queue[T]
+	pq       priority.Queue[entry[T]]
+	dequeued func(value T)
+	waits    *latency.Recorder
+	lateness *latency.Recorder
 	empty    T
 	options  *options
 	wakeup   chan struct{}
 	mu       sync.Mutex
+	consumer sync.Mutex
 	w        sync.WaitGroup
 	timer    *time.Timer
-	sleeping int32
 	closed   bool
 }
 
 func New[T any](opts ...Option) Queue[T] {
+	return newDelayQueue[T](opts...)
+}
+
+func newDelayQueue[T any](opts ...Option) *delayQueue[T] {
 	var q = &delayQueue[T]{}
 	q.options = &options{
 		unit: time.Second,
@@ -97,8 +205,12 @@ func New[T any](opts ...Option) Queue[T] {
 			opt(q.options)
 		}
 	}
-	q.pq = priority.New[T]()
-	q.wakeup = make(chan struct{})
+	q.pq = priority.New[entry[T]]()
+	if q.options.tracking {
+		q.waits = latency.NewRecorder(0)
+		q.lateness = latency.NewRecorder(0)
+	}
+	q.wakeup = make(chan struct{}, 1)
 	return q
 }
 
@@ -112,86 +224,241 @@ func (dq *delayQueue[T]) Enqueue(value T, expiration int64) priority.Element {
 	dq.mu.Lock()
 	if dq.closed {
 		dq.mu.Unlock()
+		dq.drop(1)
 		return nil
 	}
 
-	var ele = dq.pq.Enqueue(value, expiration)
+	var ele = dq.push(value, expiration)
+	var first = ele != nil && ele.First()
+	dq.observeEnqueue(1)
 	dq.mu.Unlock()
 
-	if ele != nil && ele.First() {
-		if atomic.CompareAndSwapInt32(&dq.sleeping, 1, 0) {
-			dq.wakeup <- struct{}{}
-		}
+	if first {
+		dq.notify()
 	}
 	return ele
 }
 
+func (dq *delayQueue[T]) EnqueueBatch(values []T, expirations []int64) []priority.Element {
+	dq.mu.Lock()
+	if dq.closed {
+		dq.mu.Unlock()
+		dq.drop(len(values))
+		return nil
+	}
+
+	var eles = dq.pushBatch(values, expirations)
+	var first = false
+	for _, ele := range eles {
+		if ele.First() {
+			first = true
+			break
+		}
+	}
+	if len(eles) > 0 {
+		dq.observeEnqueue(len(eles))
+	}
+	dq.mu.Unlock()
+
+	if first {
+		dq.notify()
+	}
+	return eles
+}
+
 func (dq *delayQueue[T]) Dequeue() (T, int64) {
-	var value T
-	var expiration int64
-	var delay int64
-	var found bool
-	var done bool
+	var item, ok = dq.DequeueItem()
+	if !ok {
+		return dq.empty, -1
+	}
+	return item.Value, item.Expiration
+}
+
+func (dq *delayQueue[T]) DequeueItem() (Item[T], bool) {
+	var item Item[T]
+
+	var ok = dq.wait(func(now int64) (int64, bool) {
+		var delay int64
+		var found bool
+		item, delay, found = dq.pop(now)
+		return delay, found
+	})
+	return item, ok
+}
+
+func (dq *delayQueue[T]) DequeueBatch(max int) []Item[T] {
+	var items []Item[T]
+
+	dq.wait(func(now int64) (int64, bool) {
+		var item, delay, found = dq.pop(now)
+		if !found {
+			return delay, false
+		}
+
+		items = append(items, item)
+		for (max <= 0 || len(items) < max) && dq.acquire(now) {
+			if item, _, found = dq.pop(now); !found {
+				break
+			}
+			items = append(items, item)
+		}
+		return 0, true
+	})
+
+	return items
+}
+
+func (dq *delayQueue[T]) Stats() Stats {
+	if !dq.options.tracking {
+		return Stats{}
+	}
+	return Stats{Wait: dq.waits.Stats(), Lateness: dq.lateness.Stats()}
+}
+
+// push 添加元素到队列，调用方需要持有锁
+func (dq *delayQueue[T]) push(value T, expiration int64) priority.Element {
+	return dq.pq.Enqueue(entry[T]{value: value, enqueued: dq.now()}, expiration)
+}
+
+// pushBatch 批量添加元素到队列，调用方需要持有锁
+func (dq *delayQueue[T]) pushBatch(values []T, expirations []int64) []priority.Element {
+	if len(values) != len(expirations) {
+		return nil
+	}
+
+	var now = dq.now()
+	var entries = make([]entry[T], len(values))
+	for i, value := range values {
+		entries[i] = entry[T]{value: value, enqueued: now}
+	}
+	return dq.pq.EnqueueBatch(entries, expirations)
+}
+
+// now 获取记录元素入队时间使用的时间戳，没有设定 WithLatencyTracking 时返回 0
+func (dq *delayQueue[T]) now() int64 {
+	if !dq.options.tracking {
+		return 0
+	}
+	return time.Now().UnixNano()
+}
+
+// acquire 如果队列中的第一个元素已经过期，则从限流器中获取一个令牌，没有可用的令牌时返回 false，调用方需要持有锁
+// 没有设定 WithRateLimit 或者队列已关闭时总是返回 true
+func (dq *delayQueue[T]) acquire(now int64) bool {
+	if dq.options.limiter == nil || dq.closed {
+		return true
+	}
+	var _, expiration, ok = dq.pq.Front()
+	if !ok || expiration > now {
+		return true
+	}
+	return dq.options.limiter.Allow()
+}
+
+// pop 获取队列中已过期的第一个元素，同时返回距离下一个元素过期的时间，调用方需要持有锁
+func (dq *delayQueue[T]) pop(now int64) (Item[T], int64, bool) {
+	var e, expiration, delay, found = dq.pq.PopIf(now)
+	if !found {
+		return Item[T]{}, delay, false
+	}
+
+	var item = Item[T]{Value: e.value, Expiration: expiration}
+	item.Lateness = time.Duration(now-expiration) * dq.options.unit
+	if e.enqueued != 0 {
+		item.EnqueuedAt = time.Unix(0, e.enqueued)
+		item.Wait = time.Since(item.EnqueuedAt)
+		dq.waits.Record(item.Wait)
+		dq.lateness.Record(item.Lateness)
+	}
+
+	if dq.dequeued != nil {
+		dq.dequeued(e.value)
+	}
+	if o := dq.options.observer; o != nil {
+		o.OnDequeue(observer.Event{
+			Kind:     observer.KindDelay,
+			Queue:    dq.options.name,
+			Size:     dq.pq.Len(),
+			Count:    1,
+			Latency:  item.Wait,
+			Lateness: item.Lateness,
+		})
+	}
+	if dq.closed {
+		dq.w.Done()
+	}
+	return item, delay, true
+}
+
+// wait 在持有锁的情况下调用 pop 获取已过期的元素，如果没有已过期的元素，则一直阻塞，直到有过期的元素
+// pop 的返回值为距离下一个元素过期的时间以及是否获取到元素
+// 如果队列被关闭，则返回 false
+func (dq *delayQueue[T]) wait(pop func(now int64) (int64, bool)) bool {
+	// 同一时刻只有一个调用方负责等待队列中的第一个元素过期，其它调用方在此排队，
+	// 每当有元素过期，只会有一个调用方被唤醒
+	dq.consumer.Lock()
+	defer dq.consumer.Unlock()
 
-ReadLoop:
 	for {
 		dq.mu.Lock()
 
 		if dq.closed && (!dq.options.drainAll || dq.pq.Len() == 0) {
-			done = true
 			dq.mu.Unlock()
-			break ReadLoop
+			return false
 		}
 
-		var nTime = dq.options.clock()
-		value, expiration, delay, found = dq.pq.Peek(nTime)
-		if !found {
-			atomic.StoreInt32(&dq.sleeping, 1)
+		var now = dq.options.clock()
+		var delay int64
+		var found bool
+		var throttled = !dq.acquire(now)
+		if !throttled {
+			delay, found = pop(now)
 		}
+		dq.mu.Unlock()
 
-		if found && dq.closed {
-			dq.w.Done()
+		if found {
+			return true
 		}
 
-		dq.mu.Unlock()
+		var o = dq.options.observer
+		var start time.Time
+		if o != nil {
+			start = time.Now()
+			o.OnBlock(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name})
+		}
 
-		if !found {
-			if delay == 0 {
-				select {
-				case <-dq.wakeup:
-					continue
-				}
-			} else if delay > 0 {
-				if dq.timer == nil {
-					dq.timer = time.NewTimer(time.Duration(delay) * dq.options.unit)
-				} else {
-					stopTimer(dq.timer)
-					dq.timer.Reset(time.Duration(delay) * dq.options.unit)
-				}
-
-				select {
-				case <-dq.wakeup:
-					stopTimer(dq.timer)
-					continue
-				case <-dq.timer.C:
-					if atomic.SwapInt32(&dq.sleeping, 0) == 0 {
-						<-dq.wakeup
-					}
-					continue
-				}
-			}
+		var d time.Duration
+		if throttled {
+			// 已经有过期的元素，但是没有可用的令牌
+			d = dq.options.limiter.Delay(1)
+		} else if delay <= 0 {
+			<-dq.wakeup
+			dq.observeWakeup(start)
+			continue
+		} else {
+			d = time.Duration(delay) * dq.options.unit
 		}
 
-		break ReadLoop
-	}
+		if dq.timer == nil {
+			dq.timer = time.NewTimer(d)
+		} else {
+			stopTimer(dq.timer)
+			dq.timer.Reset(d)
+		}
+		if o != nil {
+			o.OnTimerReset(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Latency: d})
+		}
 
-	if done {
-		value = dq.empty
-		expiration = -1
+		select {
+		case <-dq.wakeup:
+			stopTimer(dq.timer)
+			dq.observeWakeup(start)
+		case <-dq.timer.C:
+			if o != nil {
+				o.OnUnblock(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Latency: time.Since(start)})
+			}
+		}
 	}
-
-	atomic.StoreInt32(&dq.sleeping, 0)
-	return value, expiration
 }
 
 func (dq *delayQueue[T]) Update(ele priority.Element, expiration int64) {
@@ -202,34 +469,27 @@ func (dq *delayQueue[T]) Update(ele priority.Element, expiration int64) {
 	}
 
 	dq.pq.Update(ele, expiration)
+	var first = ele != nil && ele.First()
 	dq.mu.Unlock()
 
-	if ele.First() {
-		if atomic.CompareAndSwapInt32(&dq.sleeping, 1, 0) {
-			dq.wakeup <- struct{}{}
-		}
+	if first {
+		dq.notify()
 	}
 }
 
 func (dq *delayQueue[T]) Remove(ele priority.Element) {
-	var first = false
-	if ele != nil {
-		first = ele.First()
-	}
-
 	dq.mu.Lock()
 	if dq.closed {
 		dq.mu.Unlock()
 		return
 	}
 
-	dq.pq.Remove(ele)
+	var first = ele != nil && ele.First()
+	dq.remove(ele)
 	dq.mu.Unlock()
 
 	if first {
-		if atomic.CompareAndSwapInt32(&dq.sleeping, 1, 0) {
-			dq.wakeup <- struct{}{}
-		}
+		dq.notify()
 	}
 }
 
@@ -241,9 +501,10 @@ func (dq *delayQueue[T]) Close() {
 	}
 
 	dq.closed = true
+	dq.notify()
 
-	if atomic.LoadInt32(&dq.sleeping) == 1 {
-		dq.wakeup <- struct{}{}
+	if o := dq.options.observer; o != nil {
+		o.OnClose(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Size: dq.pq.Len()})
 	}
 
 	if dq.options.drainAll {
@@ -265,6 +526,14 @@ func (dq *delayQueue[T]) Closed() bool {
 	return dq.closed
 }
 
+// notify 唤醒正在等待的 Dequeue，如果当前没有正在等待的 Dequeue，则下一次等待会立即返回并重新检查队列
+func (dq *delayQueue[T]) notify() {
+	select {
+	case dq.wakeup <- struct{}{}:
+	default:
+	}
+}
+
 func stopTimer(timer *time.Timer) {
 	if !timer.Stop() {
 		select {
@@ -273,3 +542,36 @@ func stopTimer(timer *time.Timer) {
 		}
 	}
 }
+
+// observeEnqueue 调用方需要持有锁
+func (dq *delayQueue[T]) observeEnqueue(n int) {
+	if o := dq.options.observer; o != nil {
+		o.OnEnqueue(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Size: dq.pq.Len(), Count: n})
+	}
+}
+
+func (dq *delayQueue[T]) observeWakeup(start time.Time) {
+	if o := dq.options.observer; o != nil {
+		o.OnWakeup(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name})
+		o.OnUnblock(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Latency: time.Since(start)})
+	}
+}
+
+// remove 从队列中删除元素，删除成功时通知 Observer，调用方需要持有锁
+func (dq *delayQueue[T]) remove(ele priority.Element) bool {
+	var n = dq.pq.Len()
+	dq.pq.Remove(ele)
+	if dq.pq.Len() == n {
+		return false
+	}
+	if o := dq.options.observer; o != nil {
+		o.OnDrop(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Size: dq.pq.Len(), Count: 1})
+	}
+	return true
+}
+
+func (dq *delayQueue[T]) drop(n int) {
+	if o := dq.options.observer; o != nil {
+		o.OnDrop(observer.Event{Kind: observer.KindDelay, Queue: dq.options.name, Count: n, Producer: true})
+	}
+}
@@ -2,8 +2,11 @@ package delay_test
 
 import (
 	"github.com/smartwalle/queue/delay"
+	"github.com/smartwalle/queue/observer"
 	"github.com/smartwalle/queue/priority"
+	"github.com/smartwalle/queue/rate"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 )
@@ -186,3 +189,288 @@ func TestDelayQueue_DrainAll_Close2(t *testing.T) {
 		t.Fatal("队列已关闭，Enqueue 的返回值应该是 nil")
 	}
 }
+
+func TestDelayQueue_EnqueueBatch(t *testing.T) {
+	var q = delay.New[int]()
+
+	var now = time.Now().Unix()
+	var eles = q.EnqueueBatch([]int{3, 1, 2}, []int64{now + 3, now - 1, now})
+	if len(eles) != 3 || q.Len() != 3 {
+		t.Fatal("EnqueueBatch 添加的元素数量与预期不符")
+	}
+
+	q.Remove(eles[0])
+
+	for _, expect := range []int{1, 2} {
+		if value, _ := q.Dequeue(); value != expect {
+			t.Fatal("出队顺序与预期不符", expect, value)
+		}
+	}
+
+	q.Close()
+	if eles = q.EnqueueBatch([]int{4}, []int64{now}); eles != nil {
+		t.Fatal("队列已关闭，EnqueueBatch 的返回值应该是 nil")
+	}
+}
+
+func BenchmarkDelayQueue_DequeueBatch(b *testing.B) {
+	var q = delay.New[int]()
+	var now = time.Now().Unix()
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(i, now)
+	}
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; {
+		var items = q.DequeueBatch(1024)
+		if items == nil {
+			b.Fatal("DequeueBatch 异常")
+		}
+		n += len(items)
+	}
+}
+
+func TestDelayQueue_DequeueBatch(t *testing.T) {
+	var q = delay.New[int]()
+
+	var now = time.Now().Unix()
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i, now-int64(i))
+	}
+	q.Enqueue(10, now+1)
+
+	var items = q.DequeueBatch(4)
+	if len(items) != 4 {
+		t.Fatal("DequeueBatch 获取到的元素数量与预期不符", len(items))
+	}
+	for idx, item := range items {
+		if item.Value != 9-idx || item.Expiration != now-int64(9-idx) {
+			t.Fatal("出队顺序与预期不符", item)
+		}
+	}
+
+	if items = q.DequeueBatch(0); len(items) != 6 {
+		t.Fatal("DequeueBatch 获取到的元素数量与预期不符", len(items))
+	}
+
+	// 只剩下未过期的元素，DequeueBatch 会阻塞到该元素过期
+	if items = q.DequeueBatch(0); len(items) != 1 || items[0].Value != 10 {
+		t.Fatal("DequeueBatch 获取到的元素与预期不符", items)
+	}
+
+	q.Close()
+	if items = q.DequeueBatch(0); items != nil {
+		t.Fatal("队列已关闭，DequeueBatch 的返回值应该是 nil")
+	}
+}
+
+// millisecond 在 opts 之前添加以毫秒为单位的时间选项，供本包的测试共用
+func millisecond(opts ...delay.Option) []delay.Option {
+	return append([]delay.Option{
+		delay.WithTimeUnit(time.Millisecond),
+		delay.WithTimeProvider(func() int64 {
+			return time.Now().UnixMilli()
+		}),
+	}, opts...)
+}
+
+func newMillisecondQueue[T any](opts ...delay.Option) delay.Queue[T] {
+	return delay.New[T](millisecond(opts...)...)
+}
+
+func TestDelayQueue_MultipleConsumers(t *testing.T) {
+	const consumers = 8
+	const producers = 4
+	const count = 2000
+
+	var q = newMillisecondQueue[int](delay.WithDrainAll())
+
+	var mu sync.Mutex
+	var seen = make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func(batch bool) {
+			defer wg.Done()
+			for {
+				var values []int
+				if batch {
+					var items = q.DequeueBatch(16)
+					if items == nil {
+						return
+					}
+					for _, item := range items {
+						values = append(values, item.Value)
+					}
+				} else {
+					var value, expiration = q.Dequeue()
+					if expiration == -1 {
+						return
+					}
+					values = append(values, value)
+				}
+
+				mu.Lock()
+				for _, value := range values {
+					seen[value]++
+				}
+				mu.Unlock()
+			}
+		}(i%2 == 0)
+	}
+
+	var pw sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		pw.Add(1)
+		go func(p int) {
+			defer pw.Done()
+			var r = rand.New(rand.NewSource(int64(p)))
+			var elements []priority.Element
+			for i := p; i < count; i += producers {
+				var ele = q.Enqueue(i, time.Now().UnixMilli()+r.Int63n(50))
+				elements = append(elements, ele)
+				if i%7 == 0 {
+					q.Update(elements[r.Intn(len(elements))], time.Now().UnixMilli()+r.Int63n(20))
+				}
+			}
+		}(p)
+	}
+	pw.Wait()
+
+	q.Close()
+	wg.Wait()
+
+	if len(seen) != count {
+		t.Fatal("出队的元素数量与预期不符", len(seen))
+	}
+	for value, n := range seen {
+		if n != 1 {
+			t.Fatal("元素重复出队", value, n)
+		}
+	}
+}
+
+func TestDelayQueue_MultipleConsumers_Close(t *testing.T) {
+	var q = newMillisecondQueue[int]()
+	q.Enqueue(1, time.Now().UnixMilli()+100000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Dequeue()
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	q.Close()
+
+	var done = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close 之后所有的 Dequeue 都应该返回")
+	}
+}
+
+func TestDelayQueue_Observer(t *testing.T) {
+	var mu sync.Mutex
+	var events = make(map[string]int)
+	var lateness time.Duration = -1
+	var record = func(name string) func(e observer.Event) {
+		return func(e observer.Event) {
+			mu.Lock()
+			events[name]++
+			if name == "dequeue" && lateness < 0 {
+				lateness = e.Lateness
+			}
+			mu.Unlock()
+		}
+	}
+
+	var q = newMillisecondQueue[int](delay.WithName("test"), delay.WithObserver(observer.Funcs{
+		Enqueue:    record("enqueue"),
+		Dequeue:    record("dequeue"),
+		Drop:       record("drop"),
+		Wakeup:     record("wakeup"),
+		TimerReset: record("timer"),
+		Close:      record("close"),
+	}))
+
+	var done = make(chan struct{})
+	go func() {
+		q.Dequeue()
+		close(done)
+	}()
+
+	// 等待消费者进入阻塞状态之后再添加元素，消费者会被唤醒并设定定时器
+	time.Sleep(time.Millisecond * 20)
+	q.Enqueue(1, time.Now().UnixMilli()+20)
+	<-done
+
+	q.Close()
+	q.Enqueue(2, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{"enqueue", "dequeue", "drop", "wakeup", "timer", "close"} {
+		if events[name] == 0 {
+			t.Fatal("没有收到事件", name)
+		}
+	}
+	if lateness < 0 {
+		t.Fatal("元素出队的时间不应该早于其过期时间", lateness)
+	}
+}
+
+func TestDelayQueue_LatencyTracking(t *testing.T) {
+	var q = newMillisecondQueue[int](delay.WithLatencyTracking())
+
+	var expiration = time.Now().UnixMilli() + 20
+	q.Enqueue(1, expiration)
+	q.Enqueue(2, 0)
+
+	var item, ok = q.DequeueItem()
+	if !ok || item.Value != 2 || item.EnqueuedAt.IsZero() {
+		t.Fatal("DequeueItem 获取到的元素与预期不符", item)
+	}
+
+	item, ok = q.DequeueItem()
+	if !ok || item.Value != 1 || item.Expiration != expiration {
+		t.Fatal("DequeueItem 获取到的元素与预期不符", item)
+	}
+	if item.Wait < time.Millisecond*15 || item.Lateness < 0 {
+		t.Fatal("元素的等待时间或者出队延迟与预期不符", item.Wait, item.Lateness)
+	}
+
+	var stats = q.Stats()
+	if stats.Wait.Count != 2 || stats.Lateness.Count != 2 || stats.Wait.Max != item.Wait {
+		t.Fatal("Stats 与预期不符", stats)
+	}
+
+	q.Close()
+	if _, ok = q.DequeueItem(); ok {
+		t.Fatal("队列关闭之后 DequeueItem 应该返回 false")
+	}
+}
+
+func TestDelayQueue_RateLimit(t *testing.T) {
+	var q = newMillisecondQueue[int](delay.WithRateLimit(rate.NewLimiter(100, 2)))
+	q.EnqueueBatch([]int{1, 2, 3, 4}, []int64{0, 0, 0, 0})
+
+	if items := q.DequeueBatch(0); len(items) != 2 {
+		t.Fatal("DequeueBatch 获取到的元素数量应该与可用令牌数量相同", len(items))
+	}
+
+	var start = time.Now()
+	if v, _ := q.Dequeue(); v != 3 || time.Since(start) < time.Millisecond*5 {
+		t.Fatal("Dequeue 应该等待令牌之后获取元素", v, time.Since(start))
+	}
+}
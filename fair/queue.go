@@ -0,0 +1,334 @@
+package fair
+
+import (
+	"github.com/smartwalle/queue/observer"
+	"sync"
+	"time"
+)
+
+type Option func(opts *options)
+
+// WithBatchSize 用于设定每一次 Dequeue 最多获取的元素数量
+// 小于等于 0 时每一次 Dequeue 按照权重轮询一次所有的流
+func WithBatchSize(size int) Option {
+	return func(opts *options) {
+		opts.batch = size
+	}
+}
+
+// WithFlowMaxSize 用于设定每一个流的最大容量，流已满时生产者会阻塞，直到该流有足够的空间
+func WithFlowMaxSize(max int) Option {
+	return func(opts *options) {
+		opts.max = max
+	}
+}
+
+// WithDefaultWeight 用于设定流的默认权重，默认为 1，可以通过 SetWeight 单独设定某一个流的权重
+func WithDefaultWeight(weight int) Option {
+	return func(opts *options) {
+		if weight < 1 {
+			weight = 1
+		}
+		opts.weight = weight
+	}
+}
+
+// WithName 用于设定队列名称，队列名称会出现在 Observer 接收到的事件中
+func WithName(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// WithObserver 用于设定队列的观察者
+func WithObserver(o observer.Observer) Option {
+	return func(opts *options) {
+		opts.observer = o
+	}
+}
+
+type options struct {
+	batch    int
+	max      int
+	weight   int
+	name     string
+	observer observer.Observer
+}
+
+// Queue 公平队列
+// 元素按照所属的流（例如租户）分别排队，Dequeue 按照各个流的权重轮询获取元素，避免某一个流占用所有的处理能力
+type Queue[K comparable, T any] interface {
+	// Len 获取队列元素数量
+	Len() int
+
+	// SetWeight 设定流的权重，权重越大，每一轮可以出队的元素越多，weight 小于 1 时使用 1
+	SetWeight(flow K, weight int)
+
+	// Enqueue 添加元素到 flow 对应的流
+	// 如果设定了 WithFlowMaxSize，并且该流已满，则会阻塞，直到该流有足够的空间
+	// 如果队列已关闭，则返回 false，否则返回 true
+	Enqueue(flow K, value T) bool
+
+	// EnqueueBatch 批量添加元素到 flow 对应的流，所有元素在同一次加锁中添加
+	// 如果设定了 WithFlowMaxSize，则会等待该流有足够的空间容纳所有元素，流为空时不受此限制
+	// 如果队列已关闭，则返回 false，否则返回 true
+	EnqueueBatch(flow K, values []T) bool
+
+	// Dequeue 按照各个流的权重获取队列中的元素
+	// 如果队列中没有元素，则本方法会一直阻塞，直到有元素
+	// 队列关闭之后，会一次获取所有剩余的元素
+	// 如果队列已关闭，则返回 false，否则返回 true
+	Dequeue(*[]T) bool
+
+	// Close 关闭队列
+	Close()
+
+	// Closed 获取队列是否关闭
+	Closed() bool
+}
+
+type flow[K comparable, T any] struct {
+	key      K
+	elements []T
+	deficit  int
+	turn     bool
+}
+
+type fairQueue[K comparable, T any] struct {
+	options *options
+	cond    *sync.Cond
+	quantum int
+	cost    func(value T) int
+	flows   map[K]*flow[K, T]
+	weights map[K]int
+	active  []*flow[K, T]
+	current int
+	size    int
+	closed  bool
+}
+
+// New 创建使用加权轮询（weighted round-robin）的公平队列，每一轮中每一个流最多出队与其权重相同数量的元素
+func New[K comparable, T any](opts ...Option) Queue[K, T] {
+	return newFairQueue[K, T](1, nil, opts...)
+}
+
+// NewDeficit 创建使用赤字轮询（deficit round-robin）的公平队列，适用于元素的处理成本不同的场景
+// 每一轮中每一个流获得 quantum * 权重 的额度，只有当元素的成本不大于该流剩余的额度时才会出队，未使用的额度会累积到下一轮
+// cost 用于计算元素的成本，为 nil 时所有元素的成本都为 1
+func NewDeficit[K comparable, T any](quantum int, cost func(value T) int, opts ...Option) Queue[K, T] {
+	if quantum < 1 {
+		quantum = 1
+	}
+	return newFairQueue[K, T](quantum, cost, opts...)
+}
+
+func newFairQueue[K comparable, T any](quantum int, cost func(value T) int, opts ...Option) *fairQueue[K, T] {
+	var q = &fairQueue[K, T]{}
+	q.options = &options{
+		weight: 1,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q.options)
+		}
+	}
+	q.quantum = quantum
+	q.cost = cost
+	q.flows = make(map[K]*flow[K, T])
+	q.weights = make(map[K]int)
+	q.cond = sync.NewCond(&sync.Mutex{})
+	return q
+}
+
+func (fq *fairQueue[K, T]) Len() int {
+	fq.cond.L.Lock()
+	defer fq.cond.L.Unlock()
+	return fq.size
+}
+
+func (fq *fairQueue[K, T]) SetWeight(flow K, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	fq.cond.L.Lock()
+	fq.weights[flow] = weight
+	fq.cond.L.Unlock()
+}
+
+func (fq *fairQueue[K, T]) Enqueue(flow K, value T) bool {
+	return fq.EnqueueBatch(flow, []T{value})
+}
+
+func (fq *fairQueue[K, T]) EnqueueBatch(key K, values []T) bool {
+	fq.cond.L.Lock()
+	if fq.options.max > 0 {
+		for !fq.closed {
+			var f, ok = fq.flows[key]
+			if !ok || len(f.elements) == 0 || len(f.elements)+len(values) <= fq.options.max {
+				break
+			}
+			fq.wait(true)
+		}
+	}
+
+	if fq.closed {
+		fq.cond.L.Unlock()
+		if o := fq.options.observer; o != nil {
+			o.OnDrop(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Count: len(values), Producer: true})
+		}
+		return false
+	}
+	if len(values) == 0 {
+		fq.cond.L.Unlock()
+		return true
+	}
+
+	var f, ok = fq.flows[key]
+	if !ok {
+		f = &flow[K, T]{key: key}
+		fq.flows[key] = f
+	}
+	if len(f.elements) == 0 {
+		fq.active = append(fq.active, f)
+	}
+	f.elements = append(f.elements, values...)
+	fq.size += len(values)
+
+	if o := fq.options.observer; o != nil {
+		o.OnEnqueue(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Size: fq.size, Count: len(values)})
+	}
+	fq.cond.L.Unlock()
+	fq.cond.Broadcast()
+	return true
+}
+
+func (fq *fairQueue[K, T]) Dequeue(elements *[]T) bool {
+	fq.cond.L.Lock()
+
+	for fq.size == 0 {
+		if fq.closed {
+			break
+		}
+		fq.wait(false)
+	}
+
+	var count = 0
+	if fq.closed {
+		// 队列已关闭，按照轮询的顺序获取所有剩余的元素
+		for fq.size > 0 {
+			count += fq.visit(elements, 0)
+		}
+	} else if fq.options.batch > 0 {
+		for fq.size > 0 && count < fq.options.batch {
+			count += fq.visit(elements, fq.options.batch-count)
+		}
+	} else {
+		// 轮询一次所有的流，如果所有流的额度都不足以出队一个元素，则继续轮询
+		for visits := len(fq.active); fq.size > 0 && (visits > 0 || count == 0); visits-- {
+			count += fq.visit(elements, 0)
+		}
+	}
+
+	if o := fq.options.observer; o != nil && count > 0 {
+		o.OnDequeue(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Size: fq.size, Count: count})
+	}
+
+	var closed = fq.closed
+	fq.cond.L.Unlock()
+	fq.cond.Broadcast()
+	return !closed
+}
+
+// visit 从当前轮到的流中获取元素，最多获取 limit 个元素，limit 小于等于 0 时不限制数量，调用方需要持有锁
+// 如果该流的额度已经用完或者该流已经没有元素，则轮到下一个流
+func (fq *fairQueue[K, T]) visit(elements *[]T, limit int) int {
+	var f = fq.active[fq.current]
+	if !f.turn {
+		f.deficit += fq.quantum * fq.weight(f.key)
+		f.turn = true
+	}
+
+	var n = 0
+	for n < len(f.elements) && (limit <= 0 || n < limit) {
+		var cost = 1
+		if fq.cost != nil {
+			cost = fq.cost(f.elements[n])
+		}
+		if cost > f.deficit {
+			break
+		}
+		f.deficit -= cost
+		n++
+	}
+
+	*elements = append(*elements, f.elements[:n]...)
+	var remain = copy(f.elements, f.elements[n:])
+	f.elements = f.elements[:remain]
+	fq.size -= n
+
+	switch {
+	case remain == 0:
+		// 流中已经没有元素，将其从轮询列表中删除，剩余的额度不会保留
+		f.deficit = 0
+		f.turn = false
+		copy(fq.active[fq.current:], fq.active[fq.current+1:])
+		fq.active[len(fq.active)-1] = nil
+		fq.active = fq.active[:len(fq.active)-1]
+		if _, ok := fq.weights[f.key]; !ok {
+			delete(fq.flows, f.key)
+		}
+		if fq.current >= len(fq.active) {
+			fq.current = 0
+		}
+	case limit > 0 && n == limit:
+		// 本次获取的元素数量已经达到上限，下一次 Dequeue 继续使用该流剩余的额度
+	default:
+		f.turn = false
+		fq.current = (fq.current + 1) % len(fq.active)
+	}
+	return n
+}
+
+// weight 获取流的权重，调用方需要持有锁
+func (fq *fairQueue[K, T]) weight(key K) int {
+	if weight, ok := fq.weights[key]; ok {
+		return weight
+	}
+	return fq.options.weight
+}
+
+func (fq *fairQueue[K, T]) Close() {
+	fq.cond.L.Lock()
+	if fq.closed {
+		fq.cond.L.Unlock()
+		return
+	}
+	fq.closed = true
+	var size = fq.size
+	fq.cond.L.Unlock()
+	fq.cond.Broadcast()
+
+	if o := fq.options.observer; o != nil {
+		o.OnClose(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Size: size})
+	}
+}
+
+func (fq *fairQueue[K, T]) Closed() bool {
+	fq.cond.L.Lock()
+	defer fq.cond.L.Unlock()
+	return fq.closed
+}
+
+// wait 等待队列状态发生变化，调用方需要持有锁
+func (fq *fairQueue[K, T]) wait(producer bool) {
+	var o = fq.options.observer
+	if o == nil {
+		fq.cond.Wait()
+		return
+	}
+
+	o.OnBlock(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Size: fq.size, Producer: producer})
+	var start = time.Now()
+	fq.cond.Wait()
+	o.OnUnblock(observer.Event{Kind: observer.KindFair, Queue: fq.options.name, Size: fq.size, Producer: producer, Latency: time.Since(start)})
+}
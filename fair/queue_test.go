@@ -0,0 +1,140 @@
+package fair_test
+
+import (
+	"github.com/smartwalle/queue/fair"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue_WeightedRoundRobin(t *testing.T) {
+	var q = fair.New[string, int]()
+	q.SetWeight("a", 2)
+
+	q.EnqueueBatch("a", []int{1, 2, 3, 4, 5})
+	q.EnqueueBatch("b", []int{10, 11, 12})
+	q.EnqueueBatch("c", []int{20})
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{1, 2, 10, 20}) {
+		t.Fatal("第一轮出队的元素与预期不符", items)
+	}
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{3, 4, 11}) {
+		t.Fatal("第二轮出队的元素与预期不符", items)
+	}
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{5, 12}) {
+		t.Fatal("第三轮出队的元素与预期不符", items)
+	}
+
+	if q.Len() != 0 {
+		t.Fatal("所有的元素都应该已经出队", q.Len())
+	}
+}
+
+func TestQueue_BatchSize(t *testing.T) {
+	var q = fair.New[string, int](fair.WithBatchSize(3))
+
+	// 吵闹的流 a 不应该阻塞流 b
+	q.EnqueueBatch("a", []int{1, 2, 3, 4, 5, 6})
+	q.Enqueue("b", 10)
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{1, 10, 2}) {
+		t.Fatal("出队的元素与预期不符", items)
+	}
+}
+
+func TestQueue_Deficit(t *testing.T) {
+	// 元素的值即为其成本
+	var q = fair.NewDeficit[string, int](4, func(value int) int {
+		return value
+	})
+
+	q.EnqueueBatch("a", []int{3, 3})
+	q.EnqueueBatch("b", []int{1, 1, 1, 1, 1})
+
+	var items []int
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{3, 1, 1, 1, 1}) {
+		t.Fatal("第一轮出队的元素与预期不符", items)
+	}
+
+	items = items[0:0]
+	q.Dequeue(&items)
+	if !reflect.DeepEqual(items, []int{3, 1}) {
+		t.Fatal("第二轮出队的元素与预期不符", items)
+	}
+}
+
+func TestQueue_FlowMaxSize(t *testing.T) {
+	var q = fair.New[string, int](fair.WithFlowMaxSize(1))
+	q.Enqueue("a", 1)
+
+	var done = make(chan struct{})
+	go func() {
+		q.Enqueue("a", 2)
+		close(done)
+	}()
+
+	// 其它流不受影响
+	q.Enqueue("b", 10)
+
+	select {
+	case <-done:
+		t.Fatal("流已满时生产者应该阻塞")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	var items []int
+	q.Dequeue(&items)
+	<-done
+
+	if q.Len() != 1 {
+		t.Fatal("阻塞的生产者应该在流有空间之后添加元素", q.Len())
+	}
+}
+
+func TestQueue_Close(t *testing.T) {
+	var q = fair.New[int, int]()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var total = 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var items []int
+		for {
+			items = items[0:0]
+			var ok = q.Dequeue(&items)
+			mu.Lock()
+			total += len(items)
+			mu.Unlock()
+			if !ok {
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		q.Enqueue(i%7, i)
+	}
+	q.Close()
+	wg.Wait()
+
+	if total != 1000 {
+		t.Fatal("队列关闭之后应该获取所有剩余的元素", total)
+	}
+	if q.Enqueue(0, 0) {
+		t.Fatal("队列关闭之后 Enqueue 应该返回 false")
+	}
+}
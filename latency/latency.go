@@ -0,0 +1,101 @@
+package latency
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWindow Recorder 默认保留的样本数量
+const DefaultWindow = 1024
+
+// Stats 耗时统计
+// Count、Min、Max 和 Mean 统计的是所有样本，百分位数统计的是最近的样本，参考 NewRecorder
+type Stats struct {
+	Count uint64
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// Recorder 记录耗时样本并计算百分位数，可以在多个 goroutine 中同时使用
+type Recorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	count   uint64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+// NewRecorder 创建 Recorder，只保留最近的 window 个样本用于计算百分位数，window 小于等于 0 时使用 DefaultWindow
+func NewRecorder(window int) *Recorder {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	var r = &Recorder{}
+	r.samples = make([]time.Duration, 0, window)
+	return r
+}
+
+// Record 添加样本
+func (r *Recorder) Record(d time.Duration) {
+	r.mu.Lock()
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, d)
+	} else {
+		r.samples[r.next] = d
+		r.next = (r.next + 1) % len(r.samples)
+	}
+
+	if r.count == 0 || d < r.min {
+		r.min = d
+	}
+	if r.count == 0 || d > r.max {
+		r.max = d
+	}
+	r.count++
+	r.sum += d
+	r.mu.Unlock()
+}
+
+// Stats 获取统计结果，如果没有任何样本，则返回零值
+func (r *Recorder) Stats() Stats {
+	r.mu.Lock()
+	if r.count == 0 {
+		r.mu.Unlock()
+		return Stats{}
+	}
+
+	var stats = Stats{Count: r.count, Min: r.min, Max: r.max}
+	stats.Mean = r.sum / time.Duration(r.count)
+
+	var samples = make([]time.Duration, len(r.samples))
+	copy(samples, r.samples)
+	r.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	stats.P50 = percentile(samples, 0.5)
+	stats.P90 = percentile(samples, 0.9)
+	stats.P99 = percentile(samples, 0.99)
+	return stats
+}
+
+// percentile 使用 nearest-rank 方法获取百分位数，samples 需要是有序的
+func percentile(samples []time.Duration, p float64) time.Duration {
+	var rank = int(math.Ceil(p*float64(len(samples)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(samples) {
+		rank = len(samples) - 1
+	}
+	return samples[rank]
+}
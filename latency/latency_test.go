@@ -0,0 +1,55 @@
+package latency_test
+
+import (
+	"github.com/smartwalle/queue/latency"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Stats(t *testing.T) {
+	var r = latency.NewRecorder(0)
+	if stats := r.Stats(); stats != (latency.Stats{}) {
+		t.Fatal("没有样本时应该返回零值", stats)
+	}
+
+	for i := 100; i >= 1; i-- {
+		r.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	var stats = r.Stats()
+	if stats.Count != 100 || stats.Min != time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Fatal("统计结果与预期不符", stats)
+	}
+	if stats.Mean != 50500*time.Microsecond {
+		t.Fatal("平均值与预期不符", stats.Mean)
+	}
+	if stats.P50 != 50*time.Millisecond || stats.P90 != 90*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Fatal("百分位数与预期不符", stats.P50, stats.P90, stats.P99)
+	}
+}
+
+func TestRecorder_Window(t *testing.T) {
+	var r = latency.NewRecorder(10)
+	for i := 1; i <= 100; i++ {
+		r.Record(time.Duration(i))
+	}
+
+	// 百分位数只统计最近的 10 个样本
+	var stats = r.Stats()
+	if stats.Count != 100 || stats.Min != 1 || stats.P50 != 95 || stats.P99 != 100 {
+		t.Fatal("统计结果与预期不符", stats)
+	}
+}
+
+func TestRecorder_NearestRank(t *testing.T) {
+	var r = latency.NewRecorder(0)
+	for i := 1; i <= 6; i++ {
+		r.Record(time.Duration(i))
+	}
+
+	// nearest-rank：P90 的序号为 ceil(0.9 * 6) = 6，P50 的序号为 ceil(0.5 * 6) = 3
+	var stats = r.Stats()
+	if stats.P50 != 3 || stats.P90 != 6 || stats.P99 != 6 {
+		t.Fatal("百分位数与预期不符", stats.P50, stats.P90, stats.P99)
+	}
+}
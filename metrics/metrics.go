@@ -0,0 +1,321 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/smartwalle/queue/observer"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 直方图默认的桶，单位为秒，与 Prometheus 客户端的默认值一致
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Option func(opts *options)
+
+// WithNamespace 用于设定指标名称的前缀，默认为 queue
+func WithNamespace(namespace string) Option {
+	return func(opts *options) {
+		opts.namespace = namespace
+	}
+}
+
+// WithBuckets 用于设定元素等待时间和延迟队列出队延迟两个直方图的桶，单位为秒
+func WithBuckets(buckets []float64) Option {
+	return func(opts *options) {
+		if len(buckets) == 0 {
+			return
+		}
+		var nBuckets = append([]float64(nil), buckets...)
+		sort.Float64s(nBuckets)
+		opts.buckets = nBuckets
+	}
+}
+
+type options struct {
+	namespace string
+	buckets   []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+type series struct {
+	kind             observer.Kind
+	depth            int
+	enqueued         uint64
+	dequeued         uint64
+	dropped          uint64
+	wakeups          uint64
+	timerResets      uint64
+	blockedProducers int
+	blockedConsumers int
+	closed           bool
+	wait             histogram
+	lateness         histogram
+}
+
+// Collector 收集队列的指标，并以 Prometheus 文本格式输出
+// Collector 实现了 observer.Observer，通过各个队列的 WithObserver 设定之后即可收集该队列的指标，多个队列可以共用同一个 Collector，使用 WithName 区分
+// Collector 实现了 http.Handler，可以直接注册到 HTTP 服务中供 Prometheus 抓取
+type Collector struct {
+	options *options
+	mu      sync.Mutex
+	queues  map[string]*series
+}
+
+func New(opts ...Option) *Collector {
+	var c = &Collector{}
+	c.options = &options{
+		namespace: "queue",
+		buckets:   DefaultBuckets,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c.options)
+		}
+	}
+	c.queues = make(map[string]*series)
+	return c
+}
+
+// series 调用方需要持有锁
+func (c *Collector) series(e observer.Event) *series {
+	var s, ok = c.queues[e.Queue]
+	if !ok {
+		s = &series{}
+		c.queues[e.Queue] = s
+	}
+	if e.Kind != "" {
+		s.kind = e.Kind
+	}
+	return s
+}
+
+func (c *Collector) OnEnqueue(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	s.depth = e.Size
+	s.enqueued += uint64(e.Count)
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnDequeue(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	s.depth = e.Size
+	s.dequeued += uint64(e.Count)
+	if e.Latency > 0 {
+		s.wait.observe(c.options.buckets, e.Latency.Seconds())
+	}
+	if e.Kind == observer.KindDelay {
+		s.lateness.observe(c.options.buckets, e.Lateness.Seconds())
+	}
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnDrop(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	if !e.Producer {
+		// 队列中已有的元素被删除
+		s.depth = e.Size
+	}
+	s.dropped += uint64(e.Count)
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnBlock(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	if e.Producer {
+		s.blockedProducers++
+	} else {
+		s.blockedConsumers++
+	}
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnUnblock(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	if e.Producer {
+		if s.blockedProducers > 0 {
+			s.blockedProducers--
+		}
+	} else if s.blockedConsumers > 0 {
+		s.blockedConsumers--
+	}
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnWakeup(e observer.Event) {
+	c.mu.Lock()
+	c.series(e).wakeups++
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnTimerReset(e observer.Event) {
+	c.mu.Lock()
+	c.series(e).timerResets++
+	c.mu.Unlock()
+}
+
+func (c *Collector) OnClose(e observer.Event) {
+	c.mu.Lock()
+	var s = c.series(e)
+	s.depth = e.Size
+	s.closed = true
+	c.mu.Unlock()
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出所有的指标
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(s *series) float64
+	hist  func(s *series) *histogram
+}
+
+var metricList = []metric{
+	{name: "depth", help: "Number of elements in the queue.", kind: "gauge", value: func(s *series) float64 { return float64(s.depth) }},
+	{name: "enqueued_total", help: "Total number of elements added to the queue.", kind: "counter", value: func(s *series) float64 { return float64(s.enqueued) }},
+	{name: "dequeued_total", help: "Total number of elements removed from the queue by consumers.", kind: "counter", value: func(s *series) float64 { return float64(s.dequeued) }},
+	{name: "dropped_total", help: "Total number of elements rejected by or removed from the queue.", kind: "counter", value: func(s *series) float64 { return float64(s.dropped) }},
+	{name: "blocked_producers", help: "Number of producers waiting for free space.", kind: "gauge", value: func(s *series) float64 { return float64(s.blockedProducers) }},
+	{name: "blocked_consumers", help: "Number of consumers waiting for elements.", kind: "gauge", value: func(s *series) float64 { return float64(s.blockedConsumers) }},
+	{name: "wakeups_total", help: "Total number of times a waiting delay queue consumer was woken up early.", kind: "counter", value: func(s *series) float64 { return float64(s.wakeups) }},
+	{name: "timer_resets_total", help: "Total number of times a delay queue reset its timer.", kind: "counter", value: func(s *series) float64 { return float64(s.timerResets) }},
+	{name: "closed", help: "Whether the queue is closed.", kind: "gauge", value: func(s *series) float64 {
+		if s.closed {
+			return 1
+		}
+		return 0
+	}},
+	{name: "wait_seconds", help: "Time elements spent in the queue before being dequeued.", kind: "histogram", hist: func(s *series) *histogram { return &s.wait }},
+	{name: "lateness_seconds", help: "Time between an element's expiration and its dequeue in a delay queue.", kind: "histogram", hist: func(s *series) *histogram { return &s.lateness }},
+}
+
+// WriteTo 以 Prometheus 文本格式将所有的指标写入 w
+// 队列在持有内部锁时通知 Collector，所以只在复制指标时持有锁，格式化和写入 w 时不会阻塞队列
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var queues = c.snapshot()
+
+	var names = make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cw = &countWriter{w: bufio.NewWriter(w)}
+	for _, m := range metricList {
+		var name = m.name
+		if c.options.namespace != "" {
+			name = c.options.namespace + "_" + name
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, m.kind)
+
+		for _, queue := range names {
+			var s = queues[queue]
+			var labels = fmt.Sprintf("queue=\"%s\",kind=\"%s\"", escape(queue), escape(string(s.kind)))
+			if m.hist == nil {
+				fmt.Fprintf(cw, "%s{%s} %s\n", name, labels, formatFloat(m.value(s)))
+				continue
+			}
+
+			var h = m.hist(s)
+			if h.count == 0 {
+				continue
+			}
+			for i, bound := range c.options.buckets {
+				fmt.Fprintf(cw, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), h.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+			fmt.Fprintf(cw, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+			fmt.Fprintf(cw, "%s_count{%s} %d\n", name, labels, h.count)
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// snapshot 复制所有队列的指标
+func (c *Collector) snapshot() map[string]*series {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var queues = make(map[string]*series, len(c.queues))
+	for name, s := range c.queues {
+		var ns = *s
+		ns.wait.counts = append([]uint64(nil), s.wait.counts...)
+		ns.lateness.counts = append([]uint64(nil), s.lateness.counts...)
+		queues[name] = &ns
+	}
+	return queues
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	var n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var _ observer.Observer = (*Collector)(nil)
@@ -0,0 +1,158 @@
+package metrics_test
+
+import (
+	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/delay"
+	"github.com/smartwalle/queue/metrics"
+	"github.com/smartwalle/queue/priority"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	var c = metrics.New()
+
+	var bq = block.New[int](block.WithName("jobs"), block.WithObserver(c))
+	bq.EnqueueBatch([]int{1, 2, 3})
+	bq.Enqueue(4)
+	var items []int
+	bq.Dequeue(&items)
+	bq.Close()
+	bq.Enqueue(5)
+
+	var dq = delay.New[int](
+		delay.WithName("timers"),
+		delay.WithObserver(c),
+		delay.WithTimeUnit(time.Millisecond),
+		delay.WithTimeProvider(func() int64 {
+			return time.Now().UnixMilli()
+		}),
+	)
+	dq.Enqueue(1, time.Now().UnixMilli())
+	dq.Dequeue()
+	dq.Close()
+
+	var server = httptest.NewServer(c)
+	defer server.Close()
+
+	var rsp, err = server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if ct := rsp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatal("Content-Type 与预期不符", ct)
+	}
+
+	var body, _ = io.ReadAll(rsp.Body)
+	var text = string(body)
+	for _, line := range []string{
+		"# TYPE queue_depth gauge",
+		`queue_depth{queue="jobs",kind="block"} 0`,
+		`queue_enqueued_total{queue="jobs",kind="block"} 4`,
+		`queue_dequeued_total{queue="jobs",kind="block"} 4`,
+		`queue_dropped_total{queue="jobs",kind="block"} 1`,
+		`queue_closed{queue="jobs",kind="block"} 1`,
+		`queue_wait_seconds_count{queue="jobs",kind="block"} 1`,
+		`queue_enqueued_total{queue="timers",kind="delay"} 1`,
+		`queue_lateness_seconds_bucket{queue="timers",kind="delay",le="+Inf"} 1`,
+		`queue_lateness_seconds_count{queue="timers",kind="delay"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Fatalf("输出中没有找到 %q：\n%s", line, text)
+		}
+	}
+
+	if strings.Contains(text, `queue_lateness_seconds_count{queue="jobs"`) {
+		t.Fatal("阻塞队列不应该输出 lateness 指标")
+	}
+}
+
+func TestCollector_Remove(t *testing.T) {
+	var c = metrics.New()
+
+	// 删除元素之后 depth 需要与 Len 一致
+	var pq = priority.New[int](priority.WithName("tasks"), priority.WithObserver(c))
+	var ele = pq.Enqueue(1, 1)
+	pq.Enqueue(2, 2)
+	pq.Remove(ele)
+	pq.Update(pq.Enqueue(3, 3), 0)
+
+	var kq = delay.NewKeyed[string, int](delay.WithName("timers"), delay.WithObserver(c))
+	var now = time.Now().Unix()
+	kq.Enqueue("a", 1, now+1000)
+	kq.Enqueue("b", 2, now+1000)
+	// 替换已存在的 key 不应该增加 enqueued_total
+	kq.Upsert("a", 3, now+2000)
+	kq.RescheduleByKey("b", now+3000)
+	kq.CancelByKey("b")
+	defer kq.Close()
+
+	var buf strings.Builder
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var text = buf.String()
+	for _, line := range []string{
+		`queue_depth{queue="tasks",kind="priority"} 2`,
+		`queue_enqueued_total{queue="tasks",kind="priority"} 3`,
+		`queue_dropped_total{queue="tasks",kind="priority"} 1`,
+		`queue_depth{queue="timers",kind="delay"} 1`,
+		`queue_enqueued_total{queue="timers",kind="delay"} 2`,
+		`queue_dropped_total{queue="timers",kind="delay"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Fatalf("输出中没有找到 %q：\n%s", line, text)
+		}
+	}
+}
+
+// blockingWriter 在 release 关闭之前阻塞所有的写入
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.started:
+	default:
+		close(w.started)
+	}
+	<-w.release
+	return len(p), nil
+}
+
+func TestCollector_SlowScrape(t *testing.T) {
+	var c = metrics.New()
+	var bq = block.New[int](block.WithName("jobs"), block.WithObserver(c))
+	bq.Enqueue(1)
+
+	var w = &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	var done = make(chan struct{})
+	go func() {
+		c.WriteTo(w)
+		close(done)
+	}()
+	<-w.started
+
+	// 抓取阻塞期间，队列的入队不应该被阻塞
+	var enqueued = make(chan struct{})
+	go func() {
+		bq.Enqueue(2)
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatal("抓取阻塞时 Enqueue 不应该被阻塞")
+	}
+
+	close(w.release)
+	<-done
+}
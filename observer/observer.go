@@ -0,0 +1,149 @@
+package observer
+
+import (
+	"time"
+)
+
+// Kind 触发事件的队列类型
+type Kind string
+
+const (
+	KindBlock    Kind = "block"
+	KindDelay    Kind = "delay"
+	KindPriority Kind = "priority"
+	KindFair     Kind = "fair"
+)
+
+// Event 队列事件
+type Event struct {
+	// Kind 队列类型
+	Kind Kind
+
+	// Queue 队列名称，通过各个队列的 WithName 设定
+	Queue string
+
+	// Size 事件发生之后队列中元素的数量
+	Size int
+
+	// Count 本次操作涉及的元素数量
+	Count int
+
+	// Producer 阻塞或者丢弃元素的是否为生产者，生产者丢弃的元素没有进入队列，此时 Size 没有意义
+	Producer bool
+
+	// Latency 与事件相关的耗时，具体含义参考 Observer 中各个回调的说明
+	Latency time.Duration
+
+	// Lateness 延迟队列中元素出队的时间与其过期时间的差值
+	Lateness time.Duration
+}
+
+// Observer 用于观察队列内部的行为，可以用于日志、链路追踪或者监控
+// 回调可能在队列内部的锁中执行，所以不能在回调中调用队列的方法，并且应该尽快返回
+type Observer interface {
+	// OnEnqueue 元素入队
+	OnEnqueue(e Event)
+
+	// OnDequeue 元素出队，Latency 为元素在队列中等待的时间，批量出队时为最早入队的元素等待的时间，无法获取时为 0
+	// 延迟队列中每一个元素出队都会触发一次，Lateness 为元素出队的时间与其过期时间的差值
+	OnDequeue(e Event)
+
+	// OnDrop 元素被丢弃，例如向已关闭的队列添加元素、写入存储失败或者有界优先级队列淘汰元素
+	// Producer 为 false 时表示队列中已有的元素被删除，例如调用 Remove 或者 CancelByKey，此时 Size 为删除之后队列中元素的数量
+	OnDrop(e Event)
+
+	// OnBlock 生产者因为队列已满而阻塞，或者消费者因为队列中没有可以出队的元素而阻塞
+	OnBlock(e Event)
+
+	// OnUnblock 阻塞结束，Latency 为阻塞的时间
+	OnUnblock(e Event)
+
+	// OnWakeup 延迟队列中正在等待的消费者被新的元素、更新操作或者关闭操作唤醒
+	OnWakeup(e Event)
+
+	// OnTimerReset 延迟队列重新设定定时器，Latency 为定时器的时长
+	OnTimerReset(e Event)
+
+	// OnClose 队列关闭
+	OnClose(e Event)
+}
+
+// Base Observer 的空实现，嵌入到自定义的 Observer 中之后只需要实现关心的回调
+type Base struct {
+}
+
+func (Base) OnEnqueue(e Event) {}
+
+func (Base) OnDequeue(e Event) {}
+
+func (Base) OnDrop(e Event) {}
+
+func (Base) OnBlock(e Event) {}
+
+func (Base) OnUnblock(e Event) {}
+
+func (Base) OnWakeup(e Event) {}
+
+func (Base) OnTimerReset(e Event) {}
+
+func (Base) OnClose(e Event) {}
+
+// Funcs 使用函数实现 Observer，未设定的函数会被忽略
+type Funcs struct {
+	Enqueue    func(e Event)
+	Dequeue    func(e Event)
+	Drop       func(e Event)
+	Block      func(e Event)
+	Unblock    func(e Event)
+	Wakeup     func(e Event)
+	TimerReset func(e Event)
+	Close      func(e Event)
+}
+
+func (f Funcs) OnEnqueue(e Event) {
+	if f.Enqueue != nil {
+		f.Enqueue(e)
+	}
+}
+
+func (f Funcs) OnDequeue(e Event) {
+	if f.Dequeue != nil {
+		f.Dequeue(e)
+	}
+}
+
+func (f Funcs) OnDrop(e Event) {
+	if f.Drop != nil {
+		f.Drop(e)
+	}
+}
+
+func (f Funcs) OnBlock(e Event) {
+	if f.Block != nil {
+		f.Block(e)
+	}
+}
+
+func (f Funcs) OnUnblock(e Event) {
+	if f.Unblock != nil {
+		f.Unblock(e)
+	}
+}
+
+func (f Funcs) OnWakeup(e Event) {
+	if f.Wakeup != nil {
+		f.Wakeup(e)
+	}
+}
+
+func (f Funcs) OnTimerReset(e Event) {
+	if f.TimerReset != nil {
+		f.TimerReset(e)
+	}
+}
+
+func (f Funcs) OnClose(e Event) {
+	if f.Close != nil {
+		f.Close(e)
+	}
+}
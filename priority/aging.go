@@ -0,0 +1,119 @@
+package priority
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+// WithLinearAging 用于开启线性老化，元素在队列中每等待 step 时长，其有效优先级的值就会减少 1，用于避免低优先级的元素一直无法出队
+// 元素的有效优先级为 priority - wait/step，由于所有元素的有效优先级随时间变化的速度相同，所以元素在堆中的排序键 priority + enqueue/step 不会随时间变化，不需要重建堆
+// step 小于等于 0 时不开启老化
+func WithLinearAging(step time.Duration) Option {
+	return func(opts *options) {
+		if step <= 0 {
+			return
+		}
+		opts.aging = agingLinear
+		opts.step = step
+	}
+}
+
+// WithAging 用于开启自定义老化，fn 根据元素的优先级及其在队列中等待的时间计算元素的有效优先级，有效优先级的值越低，其优先级越高
+// 队列在 Dequeue 和 PopIf 时检查距离上一次重新计算有效优先级是否已经超过 interval，如果超过，则重新计算所有元素的有效优先级并重建堆，时间复杂度为 O(n)
+// Front 不会修改堆，如果已经超过 interval，Front 会遍历所有元素获取有效优先级最高的元素，时间复杂度同样为 O(n)
+// 在两次重建堆之间，新添加以及更新的元素使用当时的等待时间计算有效优先级，已有元素的有效优先级保持不变
+func WithAging(fn func(priority int64, wait time.Duration) int64, interval time.Duration) Option {
+	return func(opts *options) {
+		if fn == nil {
+			return
+		}
+		opts.aging = agingFunc
+		opts.agingFunc = fn
+		opts.interval = interval
+	}
+}
+
+// WithTimeProvider 用于设定老化使用的时间源，默认为 time.Now
+func WithTimeProvider(f func() time.Time) Option {
+	return func(opts *options) {
+		if f == nil {
+			f = time.Now
+		}
+		opts.clock = f
+	}
+}
+
+type agingMode int
+
+const (
+	agingNone agingMode = iota
+	agingLinear
+	agingFunc
+)
+
+// now 获取当前时间与队列创建时间的差值，单位为纳秒
+func (pq *priorityQueue[T]) now() int64 {
+	if pq.options.aging == agingNone {
+		return 0
+	}
+	return int64(pq.options.clock().Sub(pq.epoch))
+}
+
+// key 计算元素在堆中的排序键，参数 now 为 pq.now() 的返回值
+func (pq *priorityQueue[T]) key(ele *queueElement[T], now int64) int64 {
+	switch pq.options.aging {
+	case agingLinear:
+		// priority 和 enqueued 都不小于 0，相加溢出时取 math.MaxInt64
+		var age = ele.enqueued / int64(pq.options.step)
+		if ele.priority > math.MaxInt64-age {
+			return math.MaxInt64
+		}
+		return ele.priority + age
+	case agingFunc:
+		return pq.options.agingFunc(ele.priority, time.Duration(now-ele.enqueued))
+	default:
+		return ele.priority
+	}
+}
+
+// effective 获取元素当前的有效优先级，参数 now 为 pq.now() 的返回值
+func (pq *priorityQueue[T]) effective(ele *queueElement[T], now int64) int64 {
+	if pq.options.aging == agingLinear {
+		return ele.key - now/int64(pq.options.step)
+	}
+	return ele.key
+}
+
+// due 获取距离上一次重新计算有效优先级是否已经超过设定的时间间隔
+func (pq *priorityQueue[T]) due(now int64) bool {
+	return pq.options.aging == agingFunc && now-pq.rebalanced >= int64(pq.options.interval)
+}
+
+// best 获取下一个出队的元素，不会修改堆，调用方需要保证队列不为空
+func (pq *priorityQueue[T]) best(now int64) *queueElement[T] {
+	var ele = pq.elements[0]
+	if !pq.due(now) {
+		return ele
+	}
+
+	var key = pq.key(ele, now)
+	for _, nEle := range pq.elements[1:] {
+		if nKey := pq.key(nEle, now); nKey < key {
+			ele, key = nEle, nKey
+		}
+	}
+	return ele
+}
+
+// rebalance 如果距离上一次重新计算有效优先级已经超过设定的时间间隔，则重新计算所有元素的有效优先级并重建堆
+func (pq *priorityQueue[T]) rebalance(now int64) {
+	if !pq.due(now) {
+		return
+	}
+	pq.rebalanced = now
+	for _, ele := range pq.elements {
+		ele.key = pq.key(ele, now)
+	}
+	heap.Init(pq)
+}
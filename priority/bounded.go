@@ -0,0 +1,242 @@
+package priority
+
+import (
+	"github.com/smartwalle/queue/observer"
+	"math/bits"
+)
+
+// BoundedQueue 有界优先级队列，最多保留 k 个优先级最高的元素，适用于排行榜、保留最好的 N 个候选等场景
+// 队列中元素的 priority 值越低，其优先级越高；队列使用最小-最大堆实现，可以在 O(1) 时间内获取最好和最差的元素
+type BoundedQueue[T any] interface {
+	// Len 获取队列元素数量
+	Len() int
+
+	// Cap 获取队列的最大容量
+	Cap() int
+
+	// Enqueue 添加元素到队列
+	// 如果队列已满，则淘汰队列中最差的元素并返回该元素及其优先级和 true；如果新元素不比队列中最差的元素好，则直接淘汰新元素并将其返回
+	// 如果队列未满，则返回值分别是：nil，-1 和 false
+	Enqueue(value T, priority int64) (T, int64, bool)
+
+	// Best 获取队列中最好（priority 值最低）的元素及其优先级，不会将该元素从队列中删除
+	// 如果队列中没有元素，则返回值分别是：nil，-1 和 false
+	Best() (T, int64, bool)
+
+	// Worst 获取队列中最差（priority 值最高）的元素及其优先级，不会将该元素从队列中删除
+	// 如果队列中没有元素，则返回值分别是：nil，-1 和 false
+	Worst() (T, int64, bool)
+
+	// Dequeue 获取队列中最好的元素及其优先级，并且将该元素从队列中删除
+	// 如果队列中没有元素，则返回 nil 和 -1
+	Dequeue() (T, int64)
+
+	// DequeueWorst 获取队列中最差的元素及其优先级，并且将该元素从队列中删除
+	// 如果队列中没有元素，则返回 nil 和 -1
+	DequeueWorst() (T, int64)
+}
+
+type boundedElement[T any] struct {
+	value    T
+	priority int64
+}
+
+// boundedQueue 最小-最大堆，偶数层（根节点为第 0 层）的节点不大于其所有子孙节点，奇数层的节点不小于其所有子孙节点
+type boundedQueue[T any] struct {
+	empty    T
+	elements []boundedElement[T]
+	k        int
+	options  *options
+}
+
+// NewBounded 创建最多保留 k 个元素的有界优先级队列，k 小于 1 时使用 1
+// 支持的 Option 有：WithName 和 WithObserver，被淘汰的元素会触发 OnDrop
+func NewBounded[T any](k int, opts ...Option) BoundedQueue[T] {
+	if k < 1 {
+		k = 1
+	}
+	var q = &boundedQueue[T]{}
+	q.options = &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q.options)
+		}
+	}
+	q.k = k
+	q.elements = make([]boundedElement[T], 0, k)
+	return q
+}
+
+func (bq *boundedQueue[T]) Len() int {
+	return len(bq.elements)
+}
+
+func (bq *boundedQueue[T]) Cap() int {
+	return bq.k
+}
+
+func (bq *boundedQueue[T]) Enqueue(value T, priority int64) (T, int64, bool) {
+	if priority < 0 {
+		priority = 0
+	}
+
+	if len(bq.elements) < bq.k {
+		bq.elements = append(bq.elements, boundedElement[T]{value: value, priority: priority})
+		bq.bubbleUp(len(bq.elements) - 1)
+		bq.observe(observer.Observer.OnEnqueue)
+		return bq.empty, -1, false
+	}
+
+	var i = bq.worst()
+	var worst = bq.elements[i]
+	if priority >= worst.priority {
+		bq.observe(observer.Observer.OnDrop)
+		return value, priority, true
+	}
+
+	// 使用新元素替换最差的元素，然后恢复堆的性质
+	bq.elements[i] = boundedElement[T]{value: value, priority: priority}
+	if i > 0 && bq.elements[i].priority < bq.elements[0].priority {
+		bq.swap(i, 0)
+	}
+	bq.trickleDown(i)
+	bq.observe(observer.Observer.OnEnqueue)
+	bq.observe(observer.Observer.OnDrop)
+	return worst.value, worst.priority, true
+}
+
+func (bq *boundedQueue[T]) Best() (T, int64, bool) {
+	if len(bq.elements) == 0 {
+		return bq.empty, -1, false
+	}
+	var ele = bq.elements[0]
+	return ele.value, ele.priority, true
+}
+
+func (bq *boundedQueue[T]) Worst() (T, int64, bool) {
+	if len(bq.elements) == 0 {
+		return bq.empty, -1, false
+	}
+	var ele = bq.elements[bq.worst()]
+	return ele.value, ele.priority, true
+}
+
+func (bq *boundedQueue[T]) Dequeue() (T, int64) {
+	if len(bq.elements) == 0 {
+		return bq.empty, -1
+	}
+	return bq.remove(0)
+}
+
+func (bq *boundedQueue[T]) DequeueWorst() (T, int64) {
+	if len(bq.elements) == 0 {
+		return bq.empty, -1
+	}
+	return bq.remove(bq.worst())
+}
+
+// worst 获取最差的元素的索引，调用方需要保证队列不为空
+func (bq *boundedQueue[T]) worst() int {
+	switch len(bq.elements) {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	if bq.elements[2].priority > bq.elements[1].priority {
+		return 2
+	}
+	return 1
+}
+
+func (bq *boundedQueue[T]) remove(i int) (T, int64) {
+	var ele = bq.elements[i]
+	var last = len(bq.elements) - 1
+	bq.elements[i] = bq.elements[last]
+	bq.elements[last] = boundedElement[T]{}
+	bq.elements = bq.elements[:last]
+	if i < last {
+		bq.trickleDown(i)
+	}
+	bq.observe(observer.Observer.OnDequeue)
+	return ele.value, ele.priority
+}
+
+func (bq *boundedQueue[T]) swap(i, j int) {
+	bq.elements[i], bq.elements[j] = bq.elements[j], bq.elements[i]
+}
+
+// less 在最小层比较 i 是否小于 j，在最大层比较 i 是否大于 j
+func (bq *boundedQueue[T]) less(i, j int, min bool) bool {
+	if min {
+		return bq.elements[i].priority < bq.elements[j].priority
+	}
+	return bq.elements[i].priority > bq.elements[j].priority
+}
+
+func (bq *boundedQueue[T]) bubbleUp(i int) {
+	if i == 0 {
+		return
+	}
+	var min = isMinLevel(i)
+	var parent = (i - 1) / 2
+	if bq.less(parent, i, min) {
+		// 节点应该位于另外一种层中
+		bq.swap(i, parent)
+		bq.bubbleUpGrandparent(parent, !min)
+		return
+	}
+	bq.bubbleUpGrandparent(i, min)
+}
+
+func (bq *boundedQueue[T]) bubbleUpGrandparent(i int, min bool) {
+	for i > 2 {
+		var grandparent = ((i-1)/2 - 1) / 2
+		if !bq.less(i, grandparent, min) {
+			return
+		}
+		bq.swap(i, grandparent)
+		i = grandparent
+	}
+}
+
+func (bq *boundedQueue[T]) trickleDown(i int) {
+	var min = isMinLevel(i)
+	var n = len(bq.elements)
+	for {
+		// 在子节点和孙子节点中找到最小（最小层）或者最大（最大层）的节点
+		var m = -1
+		for _, c := range [6]int{2*i + 1, 2*i + 2, 4*i + 3, 4*i + 4, 4*i + 5, 4*i + 6} {
+			if c < n && (m == -1 || bq.less(c, m, min)) {
+				m = c
+			}
+		}
+		if m == -1 || !bq.less(m, i, min) {
+			return
+		}
+
+		bq.swap(m, i)
+		if m <= 2*i+2 {
+			// m 为子节点，子节点没有子孙节点需要比较
+			return
+		}
+
+		// m 为孙子节点，交换之后需要保证 m 与其父节点的关系
+		var parent = (m - 1) / 2
+		if bq.less(parent, m, min) {
+			bq.swap(m, parent)
+		}
+		i = m
+	}
+}
+
+func (bq *boundedQueue[T]) observe(f func(observer.Observer, observer.Event)) {
+	if o := bq.options.observer; o != nil {
+		f(o, observer.Event{Kind: observer.KindPriority, Queue: bq.options.name, Size: len(bq.elements), Count: 1})
+	}
+}
+
+// isMinLevel 获取索引为 i 的节点是否位于最小层
+func isMinLevel(i int) bool {
+	return (bits.Len(uint(i+1))-1)%2 == 0
+}
@@ -0,0 +1,158 @@
+package priority_test
+
+import (
+	"github.com/smartwalle/queue/observer"
+	"github.com/smartwalle/queue/priority"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBoundedQueue_Enqueue(t *testing.T) {
+	var q = priority.NewBounded[int](3)
+
+	for _, p := range []int64{5, 1, 9} {
+		if _, _, ok := q.Enqueue(int(p), p); ok {
+			t.Fatal("队列未满时不应该淘汰元素")
+		}
+	}
+
+	// 新元素比最差的元素好，淘汰最差的元素
+	if v, p, ok := q.Enqueue(3, 3); !ok || v != 9 || p != 9 {
+		t.Fatal("淘汰的元素与预期不符", v, p, ok)
+	}
+
+	// 新元素不比最差的元素好，淘汰新元素
+	if v, p, ok := q.Enqueue(5, 5); !ok || v != 5 || p != 5 {
+		t.Fatal("淘汰的元素与预期不符", v, p, ok)
+	}
+	if v, p, ok := q.Enqueue(7, 7); !ok || v != 7 || p != 7 {
+		t.Fatal("淘汰的元素与预期不符", v, p, ok)
+	}
+
+	if q.Len() != 3 || q.Cap() != 3 {
+		t.Fatal("队列长度与预期不符", q.Len(), q.Cap())
+	}
+	if v, _, _ := q.Best(); v != 1 {
+		t.Fatal("最好的元素与预期不符", v)
+	}
+	if v, _, _ := q.Worst(); v != 5 {
+		t.Fatal("最差的元素与预期不符", v)
+	}
+}
+
+func TestBoundedQueue_Dequeue(t *testing.T) {
+	var q = priority.NewBounded[int](4)
+
+	if _, _, ok := q.Best(); ok {
+		t.Fatal("空队列不应该有最好的元素")
+	}
+	if _, _, ok := q.Worst(); ok {
+		t.Fatal("空队列不应该有最差的元素")
+	}
+	if _, p := q.Dequeue(); p != -1 {
+		t.Fatal("空队列 Dequeue 应该返回 -1", p)
+	}
+	if _, p := q.DequeueWorst(); p != -1 {
+		t.Fatal("空队列 DequeueWorst 应该返回 -1", p)
+	}
+
+	for _, p := range []int64{4, 2, 3, 1} {
+		q.Enqueue(int(p), p)
+	}
+
+	if v, _ := q.DequeueWorst(); v != 4 {
+		t.Fatal("DequeueWorst 获取到的元素与预期不符", v)
+	}
+	if v, _ := q.Dequeue(); v != 1 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", v)
+	}
+	if v, _ := q.DequeueWorst(); v != 3 {
+		t.Fatal("DequeueWorst 获取到的元素与预期不符", v)
+	}
+	if v, _ := q.Dequeue(); v != 2 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", v)
+	}
+	if q.Len() != 0 {
+		t.Fatal("队列应该为空", q.Len())
+	}
+}
+
+func TestBoundedQueue_TopK(t *testing.T) {
+	var r = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for _, k := range []int{1, 2, 3, 7, 16, 100} {
+		var q = priority.NewBounded[int64](k)
+		var all = make([]int64, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			var p = r.Int63n(500)
+			all = append(all, p)
+			q.Enqueue(p, p)
+
+			// 每一次添加元素之后检查最好和最差的元素
+			var sorted = append([]int64(nil), all...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			if len(sorted) > k {
+				sorted = sorted[:k]
+			}
+			if _, best, _ := q.Best(); best != sorted[0] {
+				t.Fatal("最好的元素与预期不符", k, best, sorted[0])
+			}
+			if _, worst, _ := q.Worst(); worst != sorted[len(sorted)-1] {
+				t.Fatal("最差的元素与预期不符", k, worst, sorted[len(sorted)-1])
+			}
+		}
+
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+		var expected = all[:k]
+
+		// 交替从两端出队
+		var lo, hi = 0, k - 1
+		for i := 0; q.Len() > 0; i++ {
+			if i%2 == 0 {
+				if _, p := q.Dequeue(); p != expected[lo] {
+					t.Fatal("Dequeue 获取到的元素与预期不符", k, p, expected[lo])
+				}
+				lo++
+			} else {
+				if _, p := q.DequeueWorst(); p != expected[hi] {
+					t.Fatal("DequeueWorst 获取到的元素与预期不符", k, p, expected[hi])
+				}
+				hi--
+			}
+		}
+	}
+}
+
+func TestBoundedQueue_Observer(t *testing.T) {
+	var enqueued, dequeued, dropped, size int
+	var q = priority.NewBounded[int](2, priority.WithName("test"), priority.WithObserver(observer.Funcs{
+		Enqueue: func(e observer.Event) {
+			enqueued += e.Count
+			size = e.Size
+		},
+		Dequeue: func(e observer.Event) {
+			dequeued += e.Count
+			size = e.Size
+		},
+		Drop: func(e observer.Event) {
+			dropped += e.Count
+			size = e.Size
+		},
+	}))
+
+	q.Enqueue(1, 1)
+	q.Enqueue(2, 2)
+	q.Enqueue(3, 3)
+	q.Enqueue(0, 0)
+	if enqueued != 3 || dropped != 2 || size != 2 {
+		t.Fatal("OnEnqueue 和 OnDrop 事件与预期不符", enqueued, dropped, size)
+	}
+
+	q.Dequeue()
+	q.DequeueWorst()
+	if dequeued != 2 || size != 0 {
+		t.Fatal("OnDequeue 事件与预期不符", dequeued, size)
+	}
+}
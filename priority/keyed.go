@@ -0,0 +1,219 @@
+package priority
+
+import (
+	"github.com/smartwalle/queue/codec"
+	"io"
+)
+
+type keyedValue[K comparable, T any] struct {
+	key   K
+	value T
+}
+
+// KeyedQueue 带 key 的优先级队列
+// 队列中每一个元素都有一个唯一的 key，可以通过 key 查找、更新和删除元素
+// 队列中元素的 priority 值越低，其优先级越高
+type KeyedQueue[K comparable, T any] interface {
+	// Len 获取队列元素数量
+	Len() int
+
+	// Enqueue 添加元素到队列
+	// 参数 priority 的值不能小于 0
+	// 如果队列中已经存在相同 key 的元素，则不会做任何修改，返回已存在的元素和 false
+	Enqueue(key K, value T, priority int64) (Element, bool)
+
+	// Upsert 添加元素到队列
+	// 参数 priority 的值不能小于 0
+	// 如果队列中已经存在相同 key 的元素，则更新该元素的值及优先级
+	Upsert(key K, value T, priority int64) Element
+
+	// Get 获取 key 对应的元素的值及其优先级，不会将该元素从队列中删除
+	// 如果队列中不存在该 key，则返回值分别是：nil，-1 和 false
+	Get(key K) (T, int64, bool)
+
+	// Element 获取 key 对应的元素
+	// 如果队列中不存在该 key，则返回 nil
+	Element(key K) Element
+
+	// Dequeue 获取队列中的第一个元素的 key、值及其优先级，并且将该元素从队列中删除
+	// 如果队列中没有元素，则返回 nil，nil 和 -1
+	Dequeue() (K, T, int64)
+
+	// Front 获取队列中的第一个元素的 key、值及其优先级，不会将该元素从队列中删除
+	// 如果队列中没有元素，则返回值分别是：nil，nil，-1 和 false
+	Front() (K, T, int64, bool)
+
+	// PopIf 获取队列中优先级小于等于参数 max 的第一个元素，语义与 Queue 的 PopIf 方法一致，额外返回该元素的 key
+	PopIf(max int64) (K, T, int64, int64, bool)
+
+	// UpdateByKey 更新 key 对应的元素的优先级
+	// 如果队列中不存在该 key，则返回 false
+	UpdateByKey(key K, priority int64) bool
+
+	// RemoveByKey 从队列中删除 key 对应的元素
+	// 如果队列中不存在该 key，则返回 false
+	RemoveByKey(key K) bool
+
+	// Snapshot 将队列中所有元素的 key、值及其优先级写入 w，不会修改队列
+	Snapshot(w io.Writer, kc codec.Codec[K], vc codec.Codec[T]) error
+
+	// Restore 从 r 中读取 Snapshot 写入的所有元素并添加到队列中，可以通过 Element 方法获取 key 对应的元素
+	// 如果队列中已经存在相同 key 的元素，则使用快照中的值及优先级替换该元素；读取失败时不会修改队列
+	Restore(r io.Reader, kc codec.Codec[K], vc codec.Codec[T]) error
+}
+
+type keyedQueue[K comparable, T any] struct {
+	pq    *priorityQueue[keyedValue[K, T]]
+	index map[K]*queueElement[keyedValue[K, T]]
+}
+
+func NewKeyed[K comparable, T any](opts ...Option) KeyedQueue[K, T] {
+	var q = &keyedQueue[K, T]{}
+	q.pq = newPriorityQueue[keyedValue[K, T]](opts...)
+	q.index = make(map[K]*queueElement[keyedValue[K, T]])
+	return q
+}
+
+func (kq *keyedQueue[K, T]) Len() int {
+	return kq.pq.Len()
+}
+
+func (kq *keyedQueue[K, T]) Enqueue(key K, value T, priority int64) (Element, bool) {
+	if ele, ok := kq.index[key]; ok {
+		return ele, false
+	}
+	var ele = kq.pq.Enqueue(keyedValue[K, T]{key: key, value: value}, priority).(*queueElement[keyedValue[K, T]])
+	kq.index[key] = ele
+	return ele, true
+}
+
+func (kq *keyedQueue[K, T]) Upsert(key K, value T, priority int64) Element {
+	var ele, ok = kq.index[key]
+	if !ok {
+		ele = kq.pq.Enqueue(keyedValue[K, T]{key: key, value: value}, priority).(*queueElement[keyedValue[K, T]])
+		kq.index[key] = ele
+		return ele
+	}
+
+	ele.value.value = value
+	kq.pq.Update(ele, priority)
+	return ele
+}
+
+func (kq *keyedQueue[K, T]) Get(key K) (T, int64, bool) {
+	var ele, ok = kq.index[key]
+	if !ok {
+		var value T
+		return value, -1, false
+	}
+	return ele.value.value, ele.priority, true
+}
+
+func (kq *keyedQueue[K, T]) Element(key K) Element {
+	var ele, ok = kq.index[key]
+	if !ok {
+		return nil
+	}
+	return ele
+}
+
+func (kq *keyedQueue[K, T]) Dequeue() (K, T, int64) {
+	var kv, priority = kq.pq.Dequeue()
+	if priority != -1 {
+		delete(kq.index, kv.key)
+	}
+	return kv.key, kv.value, priority
+}
+
+func (kq *keyedQueue[K, T]) Front() (K, T, int64, bool) {
+	var kv, priority, ok = kq.pq.Front()
+	return kv.key, kv.value, priority, ok
+}
+
+func (kq *keyedQueue[K, T]) PopIf(max int64) (K, T, int64, int64, bool) {
+	var kv, priority, delay, ok = kq.pq.PopIf(max)
+	if ok {
+		delete(kq.index, kv.key)
+	}
+	return kv.key, kv.value, priority, delay, ok
+}
+
+func (kq *keyedQueue[K, T]) UpdateByKey(key K, priority int64) bool {
+	var ele, ok = kq.index[key]
+	if !ok {
+		return false
+	}
+	kq.pq.Update(ele, priority)
+	return true
+}
+
+func (kq *keyedQueue[K, T]) RemoveByKey(key K) bool {
+	var ele, ok = kq.index[key]
+	if !ok {
+		return false
+	}
+	delete(kq.index, key)
+	kq.pq.Remove(ele)
+	return true
+}
+
+func (kq *keyedQueue[K, T]) Snapshot(w io.Writer, kc codec.Codec[K], vc codec.Codec[T]) error {
+	var sw = newSnapshotWriter(w, len(kq.pq.elements))
+	for _, ele := range kq.pq.elements {
+		var key, err = kc.Marshal(ele.value.key)
+		if err != nil {
+			return err
+		}
+		var value []byte
+		if value, err = vc.Marshal(ele.value.value); err != nil {
+			return err
+		}
+		sw.write(ele.priority, key, value)
+	}
+	return sw.close()
+}
+
+func (kq *keyedQueue[K, T]) Restore(r io.Reader, kc codec.Codec[K], vc codec.Codec[T]) error {
+	var sr, err = newSnapshotReader(r)
+	if err != nil {
+		return err
+	}
+
+	var values []keyedValue[K, T]
+	var priorities []int64
+	for i := uint64(0); i < sr.count; i++ {
+		var priority, fields, err = sr.read(2)
+		if err != nil {
+			return err
+		}
+		var kv keyedValue[K, T]
+		if kv.key, err = kc.Unmarshal(fields[0]); err != nil {
+			return err
+		}
+		if kv.value, err = vc.Unmarshal(fields[1]); err != nil {
+			return err
+		}
+		values = append(values, kv)
+		priorities = append(priorities, priority)
+	}
+	if err = sr.close(); err != nil {
+		return err
+	}
+
+	var nValues = values[:0]
+	var nPriorities = priorities[:0]
+	for i, kv := range values {
+		if _, ok := kq.index[kv.key]; ok {
+			kq.Upsert(kv.key, kv.value, priorities[i])
+			continue
+		}
+		nValues = append(nValues, kv)
+		nPriorities = append(nPriorities, priorities[i])
+	}
+
+	var eles = kq.pq.EnqueueBatch(nValues, nPriorities)
+	for i, ele := range eles {
+		kq.index[nValues[i].key] = ele.(*queueElement[keyedValue[K, T]])
+	}
+	return nil
+}
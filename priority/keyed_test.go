@@ -0,0 +1,126 @@
+package priority_test
+
+import (
+	"bytes"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/priority"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func BenchmarkKeyedQueue_Upsert(b *testing.B) {
+	var q = priority.NewKeyed[int, int]()
+
+	var r = rand.NewSource(time.Now().Unix())
+	for i := 0; i < b.N; i++ {
+		q.Upsert(i%1024, i, r.Int63())
+	}
+}
+
+func TestKeyedQueue_Enqueue(t *testing.T) {
+	var q = priority.NewKeyed[string, int]()
+
+	if _, ok := q.Enqueue("a", 1, 1); !ok {
+		t.Fatal("Enqueue 新的 key 应该成功")
+	}
+	if _, ok := q.Enqueue("a", 2, 0); ok {
+		t.Fatal("Enqueue 已存在的 key 应该失败")
+	}
+
+	if v, p, ok := q.Get("a"); !ok || v != 1 || p != 1 {
+		t.Fatal("Enqueue 已存在的 key 不应该修改元素", v, p)
+	}
+}
+
+func TestKeyedQueue_Upsert(t *testing.T) {
+	var q = priority.NewKeyed[string, int]()
+
+	q.Upsert("a", 1, 10)
+	q.Upsert("b", 2, 20)
+	q.Upsert("a", 3, 30)
+
+	if q.Len() != 2 {
+		t.Fatal("Upsert 已存在的 key 不应该新增元素")
+	}
+
+	if k, v, p, _ := q.Front(); k != "b" || v != 2 || p != 20 {
+		t.Fatal("Upsert 之后队列中的第一个元素与预期不符", k, v, p)
+	}
+
+	if v, p, ok := q.Get("a"); !ok || v != 3 || p != 30 {
+		t.Fatal("Upsert 没有更新元素的值及优先级", v, p)
+	}
+}
+
+func TestKeyedQueue_ByKey(t *testing.T) {
+	var q = priority.NewKeyed[string, int]()
+
+	for i := 0; i < 100; i++ {
+		q.Enqueue(strconv.Itoa(i), i, int64(i))
+	}
+
+	if !q.UpdateByKey("50", 0) {
+		t.Fatal("UpdateByKey 应该成功")
+	}
+	if q.UpdateByKey("none", 0) {
+		t.Fatal("UpdateByKey 不存在的 key 应该失败")
+	}
+
+	// 删除偶数 key，保证堆中元素的位置发生变化之后索引依然正确
+	for i := 0; i < 100; i += 2 {
+		if !q.RemoveByKey(strconv.Itoa(i)) {
+			t.Fatal("RemoveByKey 应该成功", i)
+		}
+	}
+	if q.RemoveByKey("0") {
+		t.Fatal("RemoveByKey 已删除的 key 应该失败")
+	}
+
+	var last int64 = -1
+	for q.Len() > 0 {
+		var k, v, p = q.Dequeue()
+		if v%2 == 0 || p < last {
+			t.Fatal("出队顺序与预期不符", k, v, p)
+		}
+		if _, _, ok := q.Get(k); ok {
+			t.Fatal("Dequeue 之后不应该再获取到该 key", k)
+		}
+		last = p
+	}
+}
+
+func TestKeyedQueue_SnapshotRestore(t *testing.T) {
+	var q = priority.NewKeyed[string, int]()
+	for i := 0; i < 10; i++ {
+		q.Enqueue(strconv.Itoa(i), i, int64(10-i))
+	}
+
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf, codec.String(), codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+
+	var nq = priority.NewKeyed[string, int]()
+	nq.Enqueue("5", 100, 0)
+	nq.Enqueue("x", 100, 100)
+
+	if err := nq.Restore(&buf, codec.String(), codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+	if nq.Len() != 11 {
+		t.Fatal("Restore 的元素数量与预期不符", nq.Len())
+	}
+	if v, p, _ := nq.Get("5"); v != 5 || p != 5 {
+		t.Fatal("Restore 应该替换已存在的 key", v, p)
+	}
+
+	// 可以通过 key 获取恢复之后的元素
+	if !nq.UpdateByKey("0", 0) || nq.Element("0") == nil {
+		t.Fatal("Restore 之后应该可以通过 key 获取元素")
+	}
+	if k, _, _ := nq.Dequeue(); k != "0" {
+		t.Fatal("Restore 之后出队顺序与预期不符", k)
+	}
+}
@@ -4,8 +4,38 @@ package priority
 
 import (
 	"container/heap"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/observer"
+	"io"
+	"time"
 )
 
+type Option func(opts *options)
+
+// WithName 用于设定队列名称，队列名称会出现在 Observer 接收到的事件中
+func WithName(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// WithObserver 用于设定队列的观察者，优先级队列只会触发 OnEnqueue 和 OnDequeue 两个回调
+func WithObserver(o observer.Observer) Option {
+	return func(opts *options) {
+		opts.observer = o
+	}
+}
+
+type options struct {
+	name      string
+	observer  observer.Observer
+	aging     agingMode
+	step      time.Duration
+	agingFunc func(priority int64, wait time.Duration) int64
+	interval  time.Duration
+	clock     func() time.Time
+}
+
 type Element interface {
 	// First 获取该元素是否为队列的第一个元素
 	First() bool
@@ -21,6 +51,8 @@ type Element interface {
 type queueElement[T any] struct {
 	value    T
 	priority int64
+	key      int64
+	enqueued int64
 	index    int
 }
 
@@ -42,6 +74,7 @@ func (ele *queueElement[T]) updatePriority(priority int64) {
 
 // Queue 优先级队列
 // 队列中元素的 priority 值越低，其优先级越高
+// 开启老化之后，元素的出队顺序由有效优先级决定，参考 WithLinearAging 和 WithAging，各个方法返回的优先级依然是添加元素时设定的优先级
 type Queue[T any] interface {
 	// Len 获取队列元素数量
 	Len() int
@@ -50,14 +83,28 @@ type Queue[T any] interface {
 	// 参数 priority 的值不能小于 0
 	Enqueue(value T, priority int64) Element
 
+	// EnqueueBatch 批量添加元素到队列，参数 values 和 priorities 的元素一一对应
+	// 所有元素添加完成之后只会重建一次堆，时间复杂度为 O(n)
+	// 如果参数 values 和 priorities 的长度不一致，则不会添加任何元素并返回 nil
+	EnqueueBatch(values []T, priorities []int64) []Element
+
 	// Dequeue 获取队列中的第一个元素及其优先级，并且将该元素从队列中删除
 	// 如果队列中没有元素，则返回 nil 和 -1
 	Dequeue() (T, int64)
 
-	// Peek 获取队列中优先级小于参数 max 的第一个元素，同时返回该元素的优先级和优先级与参数 max 的差值，最后一个返回值为是否出队成功
+	// Front 获取队列中的第一个元素及其优先级，不会将该元素从队列中删除
+	// 如果队列中没有元素，则返回值分别是：nil，-1 和 false
+	Front() (T, int64, bool)
+
+	// PopIf 获取队列中优先级小于等于参数 max 的第一个元素，同时返回该元素的优先级和优先级与参数 max 的差值，最后一个返回值为是否出队成功
 	// 如果队列中没有元素，则返回值分别是：nil，-1，0 和 false
 	// 如果队列中有元素，但是所有元素的优先级都大于参数 max 的值，则返回值分别是：nil，队列中第一个元素的优先级，队列中第一个元素的优先级与 max 的差值，false
 	// 如果队列中有元素，并且有元素的优先级小于等于参数 max 的值，则返回值分别是：队列中第一个元素，队列中第一个元素的优先级，0。并且将该元素从队列中删除，true
+	PopIf(max int64) (T, int64, int64, bool)
+
+	// Peek 与 PopIf 相同
+	//
+	// Deprecated: Peek 会将满足条件的元素从队列中删除，请使用 PopIf；如果只需要查看队列中的第一个元素，请使用 Front
 	Peek(max int64) (T, int64, int64, bool)
 
 	// Update 更新元素的优先级
@@ -65,15 +112,38 @@ type Queue[T any] interface {
 
 	// Remove 从队列中删除元素
 	Remove(ele Element)
+
+	// Snapshot 将队列中所有的元素及其优先级写入 w，不会修改队列
+	Snapshot(w io.Writer, c codec.Codec[T]) error
+
+	// Restore 从 r 中读取 Snapshot 写入的所有元素并添加到队列中，所有元素添加完成之后只会重建一次堆
+	// 返回的 Element 与快照中元素的顺序一致，读取失败时不会修改队列
+	Restore(r io.Reader, c codec.Codec[T]) ([]Element, error)
 }
 
 type priorityQueue[T any] struct {
-	empty    T
-	elements []*queueElement[T]
+	empty      T
+	elements   []*queueElement[T]
+	options    *options
+	epoch      time.Time
+	rebalanced int64
+}
+
+func New[T any](opts ...Option) Queue[T] {
+	return newPriorityQueue[T](opts...)
 }
 
-func New[T any]() Queue[T] {
+func newPriorityQueue[T any](opts ...Option) *priorityQueue[T] {
 	var q = &priorityQueue[T]{}
+	q.options = &options{
+		clock: time.Now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q.options)
+		}
+	}
+	q.epoch = q.options.clock()
 	q.elements = make([]*queueElement[T], 0, 32)
 	//q.pool = &sync.Pool{
 	//	New: func() interface{} {
@@ -88,7 +158,7 @@ func (pq *priorityQueue[T]) Len() int {
 }
 
 func (pq *priorityQueue[T]) Less(i, j int) bool {
-	return pq.elements[i].priority < pq.elements[j].priority
+	return pq.elements[i].key < pq.elements[j].key
 }
 
 func (pq *priorityQueue[T]) Swap(i, j int) {
@@ -133,16 +203,60 @@ func (pq *priorityQueue[T]) Enqueue(value T, priority int64) Element {
 	var ele = &queueElement[T]{}
 	ele.value = value
 	ele.priority = priority
+	ele.enqueued = pq.now()
+	ele.key = pq.key(ele, ele.enqueued)
 
 	heap.Push(pq, ele)
+	pq.observe(observer.Observer.OnEnqueue, 1)
 	return ele
 }
 
+func (pq *priorityQueue[T]) EnqueueBatch(values []T, priorities []int64) []Element {
+	if len(values) != len(priorities) {
+		return nil
+	}
+
+	n := len(pq.elements)
+	c := cap(pq.elements)
+	if n+len(values) > c {
+		for c < n+len(values) {
+			c = c * 2
+		}
+		npq := make([]*queueElement[T], n, c)
+		copy(npq, pq.elements)
+		pq.elements = npq
+	}
+
+	var now = pq.now()
+	var eles = make([]Element, len(values))
+	for i, value := range values {
+		var priority = priorities[i]
+		if priority < 0 {
+			priority = 0
+		}
+		var ele = &queueElement[T]{}
+		ele.value = value
+		ele.priority = priority
+		ele.enqueued = now
+		ele.key = pq.key(ele, now)
+		ele.index = n + i
+		pq.elements = append(pq.elements, ele)
+		eles[i] = ele
+	}
+
+	heap.Init(pq)
+	if len(eles) > 0 {
+		pq.observe(observer.Observer.OnEnqueue, len(eles))
+	}
+	return eles
+}
+
 func (pq *priorityQueue[T]) Dequeue() (T, int64) {
 	var value T
 	if pq.Len() == 0 {
 		return value, -1
 	}
+	pq.rebalance(pq.now())
 	var ele = heap.Pop(pq).(*queueElement[T])
 
 	value = ele.value
@@ -153,18 +267,35 @@ func (pq *priorityQueue[T]) Dequeue() (T, int64) {
 	ele.index = -1
 	//pq.pool.Put(ele)
 
+	pq.observe(observer.Observer.OnDequeue, 1)
 	return value, priority
 }
 
+func (pq *priorityQueue[T]) Front() (T, int64, bool) {
+	var value T
+	if pq.Len() == 0 {
+		return value, -1, false
+	}
+	var ele = pq.best(pq.now())
+	return ele.value, ele.priority, true
+}
+
 func (pq *priorityQueue[T]) Peek(max int64) (T, int64, int64, bool) {
+	return pq.PopIf(max)
+}
+
+func (pq *priorityQueue[T]) PopIf(max int64) (T, int64, int64, bool) {
 	var value T
 	if pq.Len() == 0 {
 		return value, -1, 0, false
 	}
 
+	var now = pq.now()
+	pq.rebalance(now)
+
 	var ele = pq.elements[0]
-	if ele.priority > max {
-		return value, ele.priority, ele.priority - max, false
+	if effective := pq.effective(ele, now); effective > max {
+		return value, ele.priority, effective - max, false
 	}
 	heap.Remove(pq, 0)
 
@@ -176,6 +307,7 @@ func (pq *priorityQueue[T]) Peek(max int64) (T, int64, int64, bool) {
 	ele.index = -1
 	//pq.pool.Put(ele)
 
+	pq.observe(observer.Observer.OnDequeue, 1)
 	return value, priority, 0, true
 }
 
@@ -193,6 +325,8 @@ func (pq *priorityQueue[T]) Update(ele Element, priority int64) {
 	}
 	ele.updatePriority(priority)
 
+	var nEle = pq.elements[ele.getIndex()]
+	nEle.key = pq.key(nEle, pq.now())
 	heap.Fix(pq, ele.getIndex())
 }
 
@@ -206,4 +340,50 @@ func (pq *priorityQueue[T]) Remove(ele Element) {
 	}
 
 	heap.Remove(pq, ele.getIndex())
+	pq.observe(observer.Observer.OnDrop, 1)
+}
+
+func (pq *priorityQueue[T]) Snapshot(w io.Writer, c codec.Codec[T]) error {
+	var sw = newSnapshotWriter(w, len(pq.elements))
+	for _, ele := range pq.elements {
+		var data, err = c.Marshal(ele.value)
+		if err != nil {
+			return err
+		}
+		sw.write(ele.priority, data)
+	}
+	return sw.close()
+}
+
+func (pq *priorityQueue[T]) Restore(r io.Reader, c codec.Codec[T]) ([]Element, error) {
+	var sr, err = newSnapshotReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []T
+	var priorities []int64
+	for i := uint64(0); i < sr.count; i++ {
+		var priority, fields, err = sr.read(1)
+		if err != nil {
+			return nil, err
+		}
+		var value T
+		if value, err = c.Unmarshal(fields[0]); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		priorities = append(priorities, priority)
+	}
+	if err = sr.close(); err != nil {
+		return nil, err
+	}
+
+	return pq.EnqueueBatch(values, priorities), nil
+}
+
+func (pq *priorityQueue[T]) observe(f func(observer.Observer, observer.Event), count int) {
+	if o := pq.options.observer; o != nil {
+		f(o, observer.Event{Kind: observer.KindPriority, Queue: pq.options.name, Size: len(pq.elements), Count: count})
+	}
 }
@@ -1,9 +1,15 @@
 package priority_test
 
 import (
+	"bytes"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/observer"
 	"github.com/smartwalle/queue/priority"
+	"math"
 	"math/rand"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -66,6 +72,27 @@ func BenchmarkPriorityQueue_Peek(b *testing.B) {
 	}
 }
 
+func BenchmarkPriorityQueue_PopIf(b *testing.B) {
+	var q = priority.New[int]()
+
+	var r = rand.NewSource(time.Now().Unix())
+	var max int64
+	for i := 0; i < b.N; i++ {
+		var p = r.Int63()
+		if p > max {
+			max = p
+		}
+
+		q.Enqueue(i, p)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		q.PopIf(max)
+	}
+}
+
 func BenchmarkPriorityQueue_Remove(b *testing.B) {
 	var q = priority.New[int]()
 
@@ -111,3 +138,274 @@ func TestPriorityQueue_Dequeue2(t *testing.T) {
 		}
 	}
 }
+
+func TestPriorityQueue_Front(t *testing.T) {
+	var q = priority.New[int]()
+
+	if _, p, ok := q.Front(); ok || p != -1 {
+		t.Fatal("队列为空，Front 的返回值应该是 -1 和 false")
+	}
+
+	q.Enqueue(3, 3)
+	q.Enqueue(1, 1)
+	q.Enqueue(2, 2)
+
+	for i := 0; i < 2; i++ {
+		if v, p, ok := q.Front(); !ok || v != 1 || p != 1 {
+			t.Fatal("Front 获取到的元素与预期不符", v, p)
+		}
+	}
+
+	if q.Len() != 3 {
+		t.Fatal("Front 不应该删除队列中的元素")
+	}
+}
+
+func TestPriorityQueue_PopIf(t *testing.T) {
+	var q = priority.New[int]()
+
+	q.Enqueue(5, 5)
+	q.Enqueue(8, 8)
+
+	if _, p, delay, ok := q.PopIf(3); ok || p != 5 || delay != 2 {
+		t.Fatal("PopIf 的返回值与预期不符", p, delay, ok)
+	}
+
+	if v, p, delay, ok := q.PopIf(5); !ok || v != 5 || p != 5 || delay != 0 {
+		t.Fatal("PopIf 的返回值与预期不符", v, p, delay, ok)
+	}
+
+	if q.Len() != 1 {
+		t.Fatal("PopIf 应该删除满足条件的元素")
+	}
+}
+
+func BenchmarkPriorityQueue_EnqueueBatch(b *testing.B) {
+	var q = priority.New[int]()
+
+	var values = make([]int, b.N)
+	var priorities = make([]int64, b.N)
+	var r = rand.NewSource(time.Now().Unix())
+	for i := 0; i < b.N; i++ {
+		values[i] = i
+		priorities[i] = r.Int63()
+	}
+
+	b.ResetTimer()
+
+	q.EnqueueBatch(values, priorities)
+}
+
+func TestPriorityQueue_EnqueueBatch(t *testing.T) {
+	var q = priority.New[int]()
+
+	q.Enqueue(5, 5)
+
+	var list = []int{6, 1, 8, 2, 9, 3, 4, 7, 0}
+	var priorities = make([]int64, len(list))
+	for idx, item := range list {
+		priorities[idx] = int64(item)
+	}
+
+	if eles := q.EnqueueBatch(list, priorities[:1]); eles != nil {
+		t.Fatal("参数长度不一致，EnqueueBatch 的返回值应该是 nil")
+	}
+
+	var eles = q.EnqueueBatch(list, priorities)
+	if len(eles) != len(list) || q.Len() != len(list)+1 {
+		t.Fatal("EnqueueBatch 添加的元素数量与预期不符")
+	}
+
+	// 返回的 Element 依然可以用于更新和删除元素
+	q.Remove(eles[1])
+	q.Update(eles[0], 100)
+
+	var expect = []int{0, 2, 3, 4, 5, 7, 8, 9, 6}
+	for _, item := range expect {
+		if v, _ := q.Dequeue(); v != item {
+			t.Fatal("出队顺序与预期不符", item, v)
+		}
+	}
+}
+
+func TestPriorityQueue_SnapshotRestore(t *testing.T) {
+	var q = priority.New[string]()
+	for i := 0; i < 100; i++ {
+		q.Enqueue(strconv.Itoa(i), int64((i*37)%100))
+	}
+
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf, codec.String()); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 100 {
+		t.Fatal("Snapshot 不应该修改队列")
+	}
+
+	var data = buf.Bytes()
+	var nq = priority.New[string]()
+
+	// 损坏的快照不会修改队列
+	var broken = append([]byte{}, data...)
+	broken[len(broken)/2]++
+	if _, err := nq.Restore(bytes.NewReader(broken), codec.String()); err == nil || nq.Len() != 0 {
+		t.Fatal("Restore 损坏的快照应该返回错误", err)
+	}
+
+	var eles, err = nq.Restore(bytes.NewReader(data), codec.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eles) != 100 || nq.Len() != 100 {
+		t.Fatal("Restore 的元素数量与预期不符", len(eles))
+	}
+
+	// 返回的 Element 可以用于更新和删除元素
+	nq.Remove(eles[0])
+	for nq.Len() > 0 {
+		var v1, p1 = q.Dequeue()
+		if p1 == 0 {
+			continue
+		}
+		var v2, p2 = nq.Dequeue()
+		if v1 != v2 || p1 != p2 {
+			t.Fatal("Restore 之后出队顺序与预期不符", v1, p1, v2, p2)
+		}
+	}
+}
+
+func TestPriorityQueue_RestoreLargeField(t *testing.T) {
+	var q = priority.New[string]()
+	var large = strings.Repeat("a", 200<<10)
+	q.Enqueue(large, 1)
+
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf, codec.String()); err != nil {
+		t.Fatal(err)
+	}
+	var nq = priority.New[string]()
+	if _, err := nq.Restore(bytes.NewReader(buf.Bytes()), codec.String()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := nq.Dequeue(); v != large {
+		t.Fatal("Restore 的元素与预期不符", len(v))
+	}
+
+	// 字段长度远大于快照的实际大小，应该返回错误，而不是按照该长度分配内存
+	var data = buf.Bytes()[:12+8]
+	data = append(data, 0xff, 0xff, 0xff, 0xf0, 'a', 'b')
+	if _, err := nq.Restore(bytes.NewReader(data), codec.String()); err != priority.ErrInvalidSnapshot {
+		t.Fatal("Restore 长度错误的快照应该返回 ErrInvalidSnapshot", err)
+	}
+}
+
+func TestPriorityQueue_Observer(t *testing.T) {
+	var enqueued, dequeued, size int
+	var q = priority.New[int](priority.WithName("test"), priority.WithObserver(observer.Funcs{
+		Enqueue: func(e observer.Event) {
+			enqueued += e.Count
+			size = e.Size
+		},
+		Dequeue: func(e observer.Event) {
+			dequeued += e.Count
+			size = e.Size
+		},
+	}))
+
+	q.Enqueue(1, 1)
+	q.EnqueueBatch([]int{2, 3, 4}, []int64{2, 3, 4})
+	if enqueued != 4 || size != 4 {
+		t.Fatal("OnEnqueue 事件与预期不符", enqueued, size)
+	}
+
+	q.Dequeue()
+	q.PopIf(0)
+	q.PopIf(2)
+	if dequeued != 2 || size != 2 {
+		t.Fatal("OnDequeue 事件与预期不符", dequeued, size)
+	}
+}
+
+func TestPriorityQueue_LinearAging(t *testing.T) {
+	var now = time.Now()
+	var q = priority.New[string](
+		priority.WithLinearAging(time.Second),
+		priority.WithTimeProvider(func() time.Time {
+			return now
+		}),
+	)
+
+	q.Enqueue("low", 10)
+
+	// 10 秒之后，low 的有效优先级为 0，与新添加的优先级为 1 的元素相比，low 应该先出队
+	now = now.Add(time.Second * 10)
+	q.Enqueue("high", 1)
+
+	if v, p, ok := q.Front(); !ok || v != "low" || p != 10 {
+		t.Fatal("老化之后 Front 获取到的元素与预期不符", v, p)
+	}
+
+	if _, _, delay, ok := q.PopIf(-1); ok || delay != 1 {
+		t.Fatal("PopIf 应该使用有效优先级进行比较", delay, ok)
+	}
+	if v, p, _, ok := q.PopIf(0); !ok || v != "low" || p != 10 {
+		t.Fatal("PopIf 获取到的元素与预期不符", v, p)
+	}
+	if v, _ := q.Dequeue(); v != "high" {
+		t.Fatal("Dequeue 获取到的元素与预期不符", v)
+	}
+}
+
+func TestPriorityQueue_Aging(t *testing.T) {
+	var now = time.Now()
+	var q = priority.New[string](
+		// 等待时间超过 1 分钟的元素拥有最高的优先级
+		priority.WithAging(func(priority int64, wait time.Duration) int64 {
+			if wait >= time.Minute {
+				return 0
+			}
+			return priority
+		}, time.Second),
+		priority.WithTimeProvider(func() time.Time {
+			return now
+		}),
+	)
+
+	q.Enqueue("low", 100)
+	now = now.Add(time.Second * 30)
+	q.Enqueue("high", 1)
+
+	if v, _, _ := q.Front(); v != "high" {
+		t.Fatal("老化之前 Front 获取到的元素与预期不符", v)
+	}
+
+	now = now.Add(time.Second * 30)
+	if v, p, _ := q.Front(); v != "low" || p != 100 {
+		t.Fatal("老化之后 Front 获取到的元素与预期不符", v, p)
+	}
+	if v, _ := q.Dequeue(); v != "low" {
+		t.Fatal("老化之后 Dequeue 获取到的元素与预期不符", v)
+	}
+}
+
+func TestPriorityQueue_LinearAgingOverflow(t *testing.T) {
+	var now = time.Now()
+	var q = priority.New[string](
+		priority.WithLinearAging(time.Nanosecond),
+		priority.WithTimeProvider(func() time.Time {
+			return now
+		}),
+	)
+
+	// 排序键 priority + enqueued/step 超过 math.MaxInt64 时不能溢出为负数
+	now = now.Add(time.Hour)
+	q.Enqueue("max", math.MaxInt64-10)
+	q.Enqueue("min", 0)
+
+	if v, _ := q.Dequeue(); v != "min" {
+		t.Fatal("Dequeue 获取到的元素与预期不符", v)
+	}
+	if v, p := q.Dequeue(); v != "max" || p != math.MaxInt64-10 {
+		t.Fatal("Dequeue 获取到的元素与预期不符", v, p)
+	}
+}
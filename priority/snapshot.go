@@ -0,0 +1,149 @@
+package priority
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var ErrInvalidSnapshot = errors.New("priority: invalid snapshot")
+
+var snapshotMagic = []byte{'P', 'Q', 'S', 1}
+
+const snapshotChunkSize = 64 << 10
+
+// snapshotWriter 快照的格式为：magic、元素数量、所有元素以及 crc32 校验和
+// 每一个元素由优先级以及若干个字段组成，每一个字段由长度和内容组成
+type snapshotWriter struct {
+	w    *bufio.Writer
+	hash hash.Hash32
+	buf  []byte
+	err  error
+}
+
+func newSnapshotWriter(w io.Writer, count int) *snapshotWriter {
+	var sw = &snapshotWriter{}
+	sw.hash = crc32.NewIEEE()
+	sw.w = bufio.NewWriter(io.MultiWriter(w, sw.hash))
+	sw.buf = make([]byte, 8)
+
+	sw.w.Write(snapshotMagic)
+	binary.BigEndian.PutUint64(sw.buf, uint64(count))
+	_, sw.err = sw.w.Write(sw.buf)
+	return sw
+}
+
+func (sw *snapshotWriter) write(priority int64, fields ...[]byte) {
+	if sw.err != nil {
+		return
+	}
+	binary.BigEndian.PutUint64(sw.buf, uint64(priority))
+	sw.w.Write(sw.buf)
+	for _, field := range fields {
+		binary.BigEndian.PutUint32(sw.buf, uint32(len(field)))
+		sw.w.Write(sw.buf[:4])
+		_, sw.err = sw.w.Write(field)
+	}
+}
+
+func (sw *snapshotWriter) close() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(sw.buf, sw.hash.Sum32())
+	var _, err = sw.w.Write(sw.buf[:4])
+	if err == nil {
+		err = sw.w.Flush()
+	}
+	return err
+}
+
+type snapshotReader struct {
+	r     *bufio.Reader
+	hash  hash.Hash32
+	buf   []byte
+	count uint64
+}
+
+func newSnapshotReader(r io.Reader) (*snapshotReader, error) {
+	var sr = &snapshotReader{}
+	sr.r = bufio.NewReader(r)
+	sr.hash = crc32.NewIEEE()
+	sr.buf = make([]byte, 8)
+
+	var magic, err = sr.next(len(snapshotMagic))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, snapshotMagic) {
+		return nil, ErrInvalidSnapshot
+	}
+	if _, err = io.ReadFull(sr.r, sr.buf); err != nil {
+		return nil, ErrInvalidSnapshot
+	}
+	sr.hash.Write(sr.buf)
+	sr.count = binary.BigEndian.Uint64(sr.buf)
+	return sr, nil
+}
+
+// next 读取 n 个字节，n 来自快照中未经校验的长度
+// n 较大时不会一次性分配 n 个字节，而是随着读取到的数据逐步扩容，避免损坏的快照导致分配过多的内存
+func (sr *snapshotReader) next(n int) ([]byte, error) {
+	if n <= snapshotChunkSize {
+		var data = make([]byte, n)
+		if _, err := io.ReadFull(sr.r, data); err != nil {
+			return nil, ErrInvalidSnapshot
+		}
+		sr.hash.Write(data)
+		return data, nil
+	}
+
+	var data bytes.Buffer
+	data.Grow(snapshotChunkSize)
+	if m, err := io.CopyN(&data, sr.r, int64(n)); err != nil || m != int64(n) {
+		return nil, ErrInvalidSnapshot
+	}
+	sr.hash.Write(data.Bytes())
+	return data.Bytes(), nil
+}
+
+func (sr *snapshotReader) read(n int) (int64, [][]byte, error) {
+	if _, err := io.ReadFull(sr.r, sr.buf); err != nil {
+		return 0, nil, ErrInvalidSnapshot
+	}
+	sr.hash.Write(sr.buf)
+	var priority = int64(binary.BigEndian.Uint64(sr.buf))
+
+	var fields = make([][]byte, n)
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(sr.r, sr.buf[:4]); err != nil {
+			return 0, nil, ErrInvalidSnapshot
+		}
+		sr.hash.Write(sr.buf[:4])
+
+		var field, err = sr.next(int(binary.BigEndian.Uint32(sr.buf[:4])))
+		if err != nil {
+			return 0, nil, err
+		}
+		fields[i] = field
+	}
+	return priority, fields, nil
+}
+
+func (sr *snapshotReader) close() error {
+	var sum = sr.hash.Sum32()
+	if _, err := io.ReadFull(sr.r, sr.buf[:4]); err != nil {
+		return ErrInvalidSnapshot
+	}
+	if binary.BigEndian.Uint32(sr.buf[:4]) != sum {
+		return ErrInvalidSnapshot
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶限流器
+// 令牌以每秒 limit 个的速度放入桶中，桶中最多容纳 burst 个令牌，每一个元素出队需要消耗一个令牌
+type Limiter struct {
+	mu     sync.Mutex
+	limit  float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  func() time.Time
+}
+
+// NewLimiter 创建令牌桶限流器，limit 为每秒放入的令牌数量，burst 为桶的容量，burst 小于 1 时使用 1
+// 创建之后桶是满的
+func NewLimiter(limit float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	var l = &Limiter{}
+	l.limit = limit
+	l.burst = float64(burst)
+	l.tokens = l.burst
+	l.clock = time.Now
+	l.last = l.clock()
+	return l
+}
+
+// advance 将从上一次更新到现在产生的令牌放入桶中，调用方需要持有锁
+func (l *Limiter) advance() {
+	var now = l.clock()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.limit)
+	}
+	l.last = now
+}
+
+// Allow 获取一个令牌，如果桶中没有令牌，则返回 false
+func (l *Limiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN 获取 n 个令牌，如果桶中没有足够的令牌，则不会获取任何令牌并返回 false
+func (l *Limiter) AllowN(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance()
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Take 获取桶中现有的令牌，最多获取 max 个，返回实际获取的令牌数量，不会等待
+func (l *Limiter) Take(max int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance()
+	var n = int(math.Min(math.Floor(l.tokens), float64(max)))
+	if n < 0 {
+		n = 0
+	}
+	l.tokens -= float64(n)
+	return n
+}
+
+// Delay 获取桶中有 n 个令牌还需要等待的时间，不会获取令牌
+// 如果 n 大于桶的容量或者 limit 小于等于 0，则返回 math.MaxInt64
+func (l *Limiter) Delay(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance()
+	return l.delay(n)
+}
+
+// delay 调用方需要持有锁
+func (l *Limiter) delay(n int) time.Duration {
+	var need = float64(n) - l.tokens
+	if need <= 0 {
+		return 0
+	}
+	if float64(n) > l.burst || l.limit <= 0 {
+		return math.MaxInt64
+	}
+	return time.Duration(math.Ceil(need / l.limit * float64(time.Second)))
+}
+
+// Wait 等待并获取 n 个令牌，如果 ctx 被取消，则返回 ctx.Err()
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		l.advance()
+		if l.tokens >= float64(n) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		var d = l.delay(n)
+		l.mu.Unlock()
+
+		var timer = time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Keyed 按照 key 分别限流，每一个 key 拥有独立的令牌桶，所有令牌桶的参数相同
+type Keyed[K comparable] struct {
+	mu       sync.Mutex
+	limit    float64
+	burst    int
+	limiters map[K]*Limiter
+}
+
+// NewKeyed 创建按照 key 分别限流的限流器，参数的含义与 NewLimiter 一致
+func NewKeyed[K comparable](limit float64, burst int) *Keyed[K] {
+	var k = &Keyed[K]{}
+	k.limit = limit
+	k.burst = burst
+	k.limiters = make(map[K]*Limiter)
+	return k
+}
+
+// Limiter 获取 key 对应的令牌桶，如果不存在则创建
+func (k *Keyed[K]) Limiter(key K) *Limiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var l, ok = k.limiters[key]
+	if !ok {
+		l = NewLimiter(k.limit, k.burst)
+		k.limiters[key] = l
+	}
+	return l
+}
+
+// Allow 从 key 对应的令牌桶中获取一个令牌，如果桶中没有令牌，则返回 false
+func (k *Keyed[K]) Allow(key K) bool {
+	return k.Limiter(key).Allow()
+}
+
+// Wait 等待并从 key 对应的令牌桶中获取 n 个令牌
+func (k *Keyed[K]) Wait(ctx context.Context, key K, n int) error {
+	return k.Limiter(key).Wait(ctx, n)
+}
+
+// Remove 删除 key 对应的令牌桶，下一次使用该 key 时会重新创建一个满的令牌桶
+func (k *Keyed[K]) Remove(key K) {
+	k.mu.Lock()
+	delete(k.limiters, key)
+	k.mu.Unlock()
+}
@@ -0,0 +1,63 @@
+package rate_test
+
+import (
+	"context"
+	"github.com/smartwalle/queue/rate"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Take(t *testing.T) {
+	var l = rate.NewLimiter(100, 5)
+
+	if n := l.Take(10); n != 5 {
+		t.Fatal("Take 最多只能获取 burst 个令牌", n)
+	}
+	if l.Allow() {
+		t.Fatal("令牌已经用完，Allow 应该返回 false")
+	}
+	if d := l.Delay(1); d <= 0 || d > time.Millisecond*10 {
+		t.Fatal("Delay 与预期不符", d)
+	}
+
+	time.Sleep(time.Millisecond * 25)
+	if n := l.Take(10); n < 2 || n > 5 {
+		t.Fatal("等待之后 Take 获取到的令牌数量与预期不符", n)
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	var l = rate.NewLimiter(200, 1)
+
+	var start = time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*15 {
+		t.Fatal("Wait 没有限制速度", elapsed)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, 1); err != context.Canceled {
+		t.Fatal("ctx 被取消之后 Wait 应该返回 ctx.Err()", err)
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	var k = rate.NewKeyed[string](1, 1)
+
+	if !k.Allow("a") || k.Allow("a") {
+		t.Fatal("同一个 key 应该共用令牌桶")
+	}
+	if !k.Allow("b") {
+		t.Fatal("不同的 key 应该使用独立的令牌桶")
+	}
+
+	k.Remove("a")
+	if !k.Allow("a") {
+		t.Fatal("Remove 之后应该重新创建令牌桶")
+	}
+}
@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"github.com/smartwalle/queue/storage"
+	"go.etcd.io/bbolt"
+)
+
+type kv struct {
+	db     *bbolt.DB
+	bucket []byte
+	owned  bool
+}
+
+// Open 打开 path 指定的 bbolt 数据库，如果文件不存在则创建，使用其中名为 bucket 的 Bucket 作为队列的存储
+// 调用存储的 Close 时会关闭数据库
+func Open(path string, bucket string, opts *bbolt.Options) (storage.Storage, error) {
+	var db, err = bbolt.Open(path, 0600, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var k *kv
+	if k, err = newKV(db, []byte(bucket)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	k.owned = true
+	return storage.NewKV(k), nil
+}
+
+// New 使用已经打开的数据库 db 中名为 bucket 的 Bucket 作为队列的存储，如果 Bucket 不存在则创建
+// 同一个数据库的不同 Bucket 可以分别用于不同的队列，调用存储的 Close 时不会关闭数据库
+func New(db *bbolt.DB, bucket string) (storage.Storage, error) {
+	var k, err = newKV(db, []byte(bucket))
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewKV(k), nil
+}
+
+func newKV(db *bbolt.DB, bucket []byte) (*kv, error) {
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var _, err = tx.CreateBucketIfNotExists(bucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return &kv{db: db, bucket: bucket}, nil
+}
+
+func (k *kv) Put(keys, values [][]byte) error {
+	return k.db.Update(func(tx *bbolt.Tx) error {
+		var b = tx.Bucket(k.bucket)
+		for i, key := range keys {
+			if err := b.Put(key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (k *kv) Delete(keys [][]byte) error {
+	return k.db.Update(func(tx *bbolt.Tx) error {
+		var b = tx.Bucket(k.bucket)
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEach bbolt 按照 key 的字节序遍历 Bucket，value 只在事务中有效，storage.NewKV 会复制 value
+func (k *kv) ForEach(fn func(key, value []byte) error) error {
+	return k.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(k.bucket).ForEach(fn)
+	})
+}
+
+func (k *kv) Close() error {
+	if !k.owned {
+		return nil
+	}
+	return k.db.Close()
+}
@@ -0,0 +1,94 @@
+package bolt_test
+
+import (
+	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/codec"
+	"github.com/smartwalle/queue/delay"
+	"github.com/smartwalle/queue/storage"
+	"github.com/smartwalle/queue/storage/bolt"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func open(t *testing.T, path string) storage.Storage {
+	var s, err = bolt.Open(path, "queue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOpen(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "queue.db")
+
+	var s = open(t, path)
+	s.Put(storage.Record{ID: 3, Data: []byte("c")}, storage.Record{ID: 1, Data: []byte("a")}, storage.Record{ID: 2, Data: []byte("b")})
+	s.Put(storage.Record{ID: 2, Data: []byte("bb")})
+	s.Delete(1, 100)
+	s.Close()
+
+	s = open(t, path)
+	defer s.Close()
+
+	var records []storage.Record
+	s.Load(func(record storage.Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if len(records) != 2 || records[0].ID != 2 || string(records[0].Data) != "bb" || records[1].ID != 3 {
+		t.Fatal("恢复的记录与预期不符", records)
+	}
+}
+
+func TestNew(t *testing.T) {
+	var db, err = bbolt.Open(filepath.Join(t.TempDir(), "queue.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 同一个数据库的不同 Bucket 分别用于阻塞队列和延迟队列
+	var bs, ds storage.Storage
+	if bs, err = bolt.New(db, "block"); err != nil {
+		t.Fatal(err)
+	}
+	if ds, err = bolt.New(db, "delay"); err != nil {
+		t.Fatal(err)
+	}
+
+	bq, err := block.NewWithStorage[int](bs, codec.JSON[int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bq.EnqueueBatch([]int{1, 2})
+	bq.Close()
+
+	dq, err := delay.NewWithStorage[string](ds, codec.JSON[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dq.Enqueue("a", time.Now().Unix()+1000)
+	dq.Close()
+
+	if bq, err = block.NewWithStorage[int](bs, codec.JSON[int]()); err != nil {
+		t.Fatal(err)
+	}
+	defer bq.Close()
+
+	var items []int
+	bq.Dequeue(&items)
+	if len(items) != 2 || items[0] != 1 || items[1] != 2 {
+		t.Fatal("恢复的元素与预期不符", items)
+	}
+
+	if dq, err = delay.NewWithStorage[string](ds, codec.JSON[string]()); err != nil {
+		t.Fatal(err)
+	}
+	defer dq.Close()
+
+	if dq.Len() != 1 {
+		t.Fatal("恢复的元素数量与预期不符", dq.Len())
+	}
+}
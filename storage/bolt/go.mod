@@ -0,0 +1,12 @@
+module github.com/smartwalle/queue/storage/bolt
+
+require (
+	github.com/smartwalle/queue v0.0.0
+	go.etcd.io/bbolt v1.3.9
+)
+
+require golang.org/x/sys v0.4.0 // indirect
+
+replace github.com/smartwalle/queue => ../../
+
+go 1.18
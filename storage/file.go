@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"github.com/smartwalle/queue/wal"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrCorruptSnapshot = errors.New("storage: snapshot corrupt")
+
+const (
+	opPut byte = iota + 1
+	opDelete
+)
+
+const (
+	walDir       = "wal"
+	snapshotFile = "snapshot"
+	headerSize   = 9
+)
+
+type FileOption func(opts *fileOptions)
+
+// WithSnapshotInterval 用于设定生成快照的时间间隔，默认为 1 分钟，小于等于 0 时只在关闭存储时生成快照
+func WithSnapshotInterval(interval time.Duration) FileOption {
+	return func(opts *fileOptions) {
+		opts.snapshotInterval = interval
+	}
+}
+
+// WithWAL 用于设定预写日志参数
+func WithWAL(opts ...wal.Option) FileOption {
+	return func(o *fileOptions) {
+		o.wal = append(o.wal, opts...)
+	}
+}
+
+type fileOptions struct {
+	snapshotInterval time.Duration
+	wal              []wal.Option
+}
+
+type fileStorage struct {
+	mu      sync.Mutex
+	smu     sync.Mutex
+	dir     string
+	options *fileOptions
+	log     *wal.Log
+	records map[uint64][]byte
+	done    chan struct{}
+	closing bool
+	closed  bool
+}
+
+// NewFile 打开 dir 目录中基于本地文件的存储，如果目录不存在则创建
+// 所有的修改都会追加到预写日志中，存储会定期将所有记录写入快照文件并截断日志
+// 打开存储时会从快照和日志中恢复所有记录，所有记录都会保存在内存中
+// 适用于需要按照任意顺序删除记录的队列，例如 delay.NewDurable；先进先出的队列可以使用 NewLog
+func NewFile(dir string, opts ...FileOption) (Storage, error) {
+	var s = &fileStorage{}
+	s.dir = dir
+	s.options = &fileOptions{
+		snapshotInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s.options)
+		}
+	}
+	s.records = make(map[uint64][]byte)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var last, err = s.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.log, err = wal.Open(filepath.Join(dir, walDir), s.options.wal...); err != nil {
+		return nil, err
+	}
+	if err = s.log.Replay(last+1, func(index uint64, data []byte) error {
+		return s.apply(data)
+	}); err != nil {
+		s.log.Close()
+		return nil, err
+	}
+
+	if s.options.snapshotInterval > 0 {
+		s.done = make(chan struct{})
+		go s.snapshotLoop(s.options.snapshotInterval)
+	}
+	return s, nil
+}
+
+func (s *fileStorage) Put(records ...Record) error {
+	var entries = make([][]byte, len(records))
+	for i, record := range records {
+		entries[i] = encodeEntry(opPut, record.ID, record.Data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if _, err := s.log.Append(entries...); err != nil {
+		return err
+	}
+	for i, record := range records {
+		s.records[record.ID] = entries[i][headerSize:]
+	}
+	return nil
+}
+
+func (s *fileStorage) Delete(ids ...uint64) error {
+	var entries = make([][]byte, len(ids))
+	for i, id := range ids {
+		entries[i] = encodeEntry(opDelete, id, nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if _, err := s.log.Append(entries...); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+func (s *fileStorage) Load(fn func(record Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	return load(s.records, fn)
+}
+
+// Close 生成快照并关闭存储
+func (s *fileStorage) Close() error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	// 生成快照需要在 closed 为 false 时进行，使用 closing 保证只有一个 Close 执行关闭流程
+	s.closing = true
+	s.mu.Unlock()
+
+	if s.done != nil {
+		close(s.done)
+	}
+	var err = s.snapshot()
+
+	s.mu.Lock()
+	s.closed = true
+	if cErr := s.log.Close(); err == nil {
+		err = cErr
+	}
+	s.mu.Unlock()
+	return err
+}
+
+func (s *fileStorage) snapshotLoop(interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.snapshot()
+		}
+	}
+}
+
+// snapshot 将所有的记录写入快照文件，并截断快照之前的日志
+func (s *fileStorage) snapshot() error {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	var last = s.log.LastIndex()
+	var records = make(map[uint64][]byte, len(s.records))
+	for id, data := range s.records {
+		records[id] = data
+	}
+	s.mu.Unlock()
+
+	var path = filepath.Join(s.dir, snapshotFile)
+	var tmp = path + ".tmp"
+	var file, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	var hash = crc32.NewIEEE()
+	var writer = bufio.NewWriter(io.MultiWriter(file, hash))
+	var buf = make([]byte, 12)
+
+	binary.BigEndian.PutUint64(buf[0:8], last)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(records)))
+	writer.Write(buf)
+
+	for id, data := range records {
+		binary.BigEndian.PutUint64(buf[0:8], id)
+		binary.BigEndian.PutUint32(buf[8:12], uint32(len(data)))
+		writer.Write(buf)
+		writer.Write(data)
+	}
+
+	if err = writer.Flush(); err == nil {
+		binary.BigEndian.PutUint32(buf[0:4], hash.Sum32())
+		_, err = file.Write(buf[0:4])
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// 重命名写入磁盘之后才能截断日志，否则系统崩溃之后可能既没有新的快照，也没有对应的日志
+	if err = syncDir(s.dir); err != nil {
+		return err
+	}
+	return s.log.TruncateFront(last + 1)
+}
+
+// syncDir 将目录项的修改写入磁盘
+func syncDir(dir string) error {
+	var file, err = os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// loadSnapshot 从快照文件中恢复记录，返回快照对应的最后一条日志的序号
+func (s *fileStorage) loadSnapshot() (uint64, error) {
+	var data, err = os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if len(data) < 16 {
+		return 0, ErrCorruptSnapshot
+	}
+	var body = data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+		return 0, ErrCorruptSnapshot
+	}
+
+	var last = binary.BigEndian.Uint64(body[0:8])
+	var count = binary.BigEndian.Uint32(body[8:12])
+	body = body[12:]
+
+	for i := uint32(0); i < count; i++ {
+		if len(body) < 12 {
+			return 0, ErrCorruptSnapshot
+		}
+		var id = binary.BigEndian.Uint64(body[0:8])
+		var size = binary.BigEndian.Uint32(body[8:12])
+		body = body[12:]
+		if uint32(len(body)) < size {
+			return 0, ErrCorruptSnapshot
+		}
+		s.records[id] = body[:size:size]
+		body = body[size:]
+	}
+	return last, nil
+}
+
+// apply 将一条日志记录应用到恢复的记录中
+func (s *fileStorage) apply(entry []byte) error {
+	if len(entry) < headerSize {
+		return wal.ErrCorrupt
+	}
+	var id = binary.BigEndian.Uint64(entry[1:9])
+
+	switch entry[0] {
+	case opPut:
+		s.records[id] = entry[headerSize:]
+	case opDelete:
+		delete(s.records, id)
+	default:
+		return wal.ErrCorrupt
+	}
+	return nil
+}
+
+func encodeEntry(op byte, id uint64, data []byte) []byte {
+	var entry = make([]byte, headerSize+len(data))
+	entry[0] = op
+	binary.BigEndian.PutUint64(entry[1:9], id)
+	copy(entry[headerSize:], data)
+	return entry
+}
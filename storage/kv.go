@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// KV 嵌入式键值数据库需要实现的接口，例如基于 bbolt 的某一个 Bucket 或者纯 Go 实现的 LSM 存储
+// 基于 bbolt 的实现位于独立的模块 github.com/smartwalle/queue/storage/bolt 中，使用该实现不会为本模块引入额外的依赖
+// ForEach 需要按照 key 的字节序从小到大遍历
+// 以 bbolt 为例，Put 和 Delete 在 db.Update 中操作同一个 Bucket，ForEach 在 db.View 中调用 Bucket 的 ForEach 即可
+type KV interface {
+	// Put 在同一个事务中写入所有的键值对
+	Put(keys, values [][]byte) error
+
+	// Delete 在同一个事务中删除所有的 key
+	Delete(keys [][]byte) error
+
+	// ForEach 遍历所有的键值对，fn 返回错误时停止遍历并返回该错误
+	ForEach(fn func(key, value []byte) error) error
+
+	// Close 关闭数据库
+	Close() error
+}
+
+type kvStorage struct {
+	mu sync.Mutex
+	kv KV
+}
+
+// NewKV 创建基于嵌入式键值数据库的存储
+// 记录的 ID 会以 8 字节大端序的形式作为 key，所以 ForEach 的遍历顺序就是 ID 从小到大的顺序
+func NewKV(kv KV) Storage {
+	return &kvStorage{kv: kv}
+}
+
+func (s *kvStorage) Put(records ...Record) error {
+	var keys = make([][]byte, len(records))
+	var values = make([][]byte, len(records))
+	for i, record := range records {
+		keys[i] = encodeKey(record.ID)
+		values[i] = record.Data
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kv.Put(keys, values)
+}
+
+func (s *kvStorage) Delete(ids ...uint64) error {
+	var keys = make([][]byte, len(ids))
+	for i, id := range ids {
+		keys[i] = encodeKey(id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kv.Delete(keys)
+}
+
+func (s *kvStorage) Load(fn func(record Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.kv.ForEach(func(key, value []byte) error {
+		if len(key) != 8 {
+			return ErrInvalidKey
+		}
+		var data = make([]byte, len(value))
+		copy(data, value)
+		return fn(Record{ID: binary.BigEndian.Uint64(key), Data: data})
+	})
+}
+
+func (s *kvStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kv.Close()
+}
+
+func encodeKey(id uint64) []byte {
+	var key = make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
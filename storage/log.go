@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/smartwalle/queue/wal"
+	"sync"
+)
+
+var ErrNotFIFO = errors.New("storage: log storage only supports appending and deleting records in order")
+
+var errStop = errors.New("storage: stop")
+
+type logStorage struct {
+	mu     sync.Mutex
+	log    *wal.Log
+	offset uint64
+}
+
+// NewLog 打开 dir 目录中基于预写日志的存储，如果目录不存在则创建，适用于先进先出的队列，例如 block.NewDurable
+// 记录只能按照 ID 连续递增的顺序写入，并且只能按照写入的顺序从头部删除，删除记录会直接截断日志，记录的内容不会保存在内存中
+// 不满足上述顺序的 Put 和 Delete 会返回 ErrNotFIFO，写入已存在的 ID 也会返回 ErrNotFIFO
+func NewLog(dir string, opts ...wal.Option) (Storage, error) {
+	var s = &logStorage{}
+
+	var log, err = wal.Open(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.log = log
+
+	// 日志的序号与记录的 ID 之间的差值是固定的，从第一条记录中恢复该差值
+	if err = log.Replay(0, func(index uint64, data []byte) error {
+		if len(data) < 8 {
+			return wal.ErrCorrupt
+		}
+		s.offset = index - binary.BigEndian.Uint64(data)
+		return errStop
+	}); err != nil && err != errStop {
+		log.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *logStorage) Put(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var entries = make([][]byte, len(records))
+	for i, record := range records {
+		var entry = make([]byte, 8+len(record.Data))
+		binary.BigEndian.PutUint64(entry, record.ID)
+		copy(entry[8:], record.Data)
+		entries[i] = entry
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next = s.log.LastIndex() + 1
+	if s.log.FirstIndex() == next {
+		// 日志为空时，由第一条记录的 ID 确定差值
+		s.offset = next - records[0].ID
+	}
+	for i, record := range records {
+		if record.ID+s.offset != next+uint64(i) {
+			return ErrNotFIFO
+		}
+	}
+
+	var _, err = s.log.Append(entries...)
+	if errors.Is(err, wal.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+func (s *logStorage) Delete(ids ...uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first, last = s.log.FirstIndex(), s.log.LastIndex()
+	var next = first
+	for _, id := range ids {
+		var index = id + s.offset
+		if index < first || index > last {
+			// 已经删除或者不存在的记录
+			continue
+		}
+		if index != next {
+			return ErrNotFIFO
+		}
+		next++
+	}
+	if next == first {
+		return nil
+	}
+
+	var err = s.log.TruncateFront(next)
+	if errors.Is(err, wal.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+func (s *logStorage) Load(fn func(record Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err = s.log.Replay(0, func(index uint64, data []byte) error {
+		if len(data) < 8 {
+			return wal.ErrCorrupt
+		}
+		return fn(Record{ID: binary.BigEndian.Uint64(data), Data: data[8:]})
+	})
+	if errors.Is(err, wal.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+func (s *logStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
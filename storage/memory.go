@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+type memoryStorage struct {
+	mu      sync.Mutex
+	records map[uint64][]byte
+}
+
+// NewMemory 创建基于内存的存储，进程退出之后数据会丢失，一般用于测试
+// Close 不会清空存储中的数据，使用同一个存储重新创建队列可以模拟进程重启
+func NewMemory() Storage {
+	var s = &memoryStorage{}
+	s.records = make(map[uint64][]byte)
+	return s
+}
+
+func (s *memoryStorage) Put(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		s.records[record.ID] = record.Data
+	}
+	return nil
+}
+
+func (s *memoryStorage) Delete(ids ...uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+func (s *memoryStorage) Load(fn func(record Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return load(s.records, fn)
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+func load(records map[uint64][]byte, fn func(record Record) error) error {
+	var ids = make([]uint64, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		if err := fn(Record{ID: id, Data: records[id]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
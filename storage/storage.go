@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"errors"
+)
+
+var ErrClosed = errors.New("storage: closed")
+
+// Record 存储中的一条记录，ID 由队列分配，Data 为队列序列化之后的元素
+type Record struct {
+	ID   uint64
+	Data []byte
+}
+
+// Storage 队列的存储后端，队列的每一次修改都会同步写入存储中
+// 同一个 Storage 只能由一个队列使用
+type Storage interface {
+	// Put 写入记录，如果已经存在相同 ID 的记录，则覆盖该记录
+	Put(records ...Record) error
+
+	// Delete 删除记录，不存在的 ID 会被忽略
+	Delete(ids ...uint64) error
+
+	// Load 按照 ID 从小到大的顺序读取所有记录
+	// 参数 fn 返回错误时会停止读取，并返回该错误
+	Load(fn func(record Record) error) error
+
+	// Close 关闭存储
+	Close() error
+}
@@ -0,0 +1,214 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/smartwalle/queue/storage"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryKV struct {
+	data map[string][]byte
+}
+
+func (kv *memoryKV) Put(keys, values [][]byte) error {
+	for i, key := range keys {
+		kv.data[string(key)] = values[i]
+	}
+	return nil
+}
+
+func (kv *memoryKV) Delete(keys [][]byte) error {
+	for _, key := range keys {
+		delete(kv.data, string(key))
+	}
+	return nil
+}
+
+func (kv *memoryKV) ForEach(fn func(key, value []byte) error) error {
+	var keys = make([]string, 0, len(kv.data))
+	for key := range kv.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn([]byte(key), kv.data[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kv *memoryKV) Close() error {
+	return nil
+}
+
+func records(t *testing.T, s storage.Storage) []storage.Record {
+	var nRecords []storage.Record
+	if err := s.Load(func(record storage.Record) error {
+		nRecords = append(nRecords, record)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return nRecords
+}
+
+// testStorage 所有存储的通用测试，reopen 用于关闭并重新打开存储
+func testStorage(t *testing.T, s storage.Storage, reopen func() storage.Storage) {
+	if err := s.Put(storage.Record{ID: 3, Data: []byte("c")}, storage.Record{ID: 1, Data: []byte("a")}, storage.Record{ID: 2, Data: []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(storage.Record{ID: 2, Data: []byte("bb")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(1, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	var check = func(s storage.Storage) {
+		var nRecords = records(t, s)
+		if len(nRecords) != 2 {
+			t.Fatal("记录数量与预期不符", nRecords)
+		}
+		if nRecords[0].ID != 2 || !bytes.Equal(nRecords[0].Data, []byte("bb")) {
+			t.Fatal("记录与预期不符", nRecords[0])
+		}
+		if nRecords[1].ID != 3 || !bytes.Equal(nRecords[1].Data, []byte("c")) {
+			t.Fatal("记录与预期不符", nRecords[1])
+		}
+	}
+
+	check(s)
+
+	s.Close()
+	s = reopen()
+	defer s.Close()
+
+	check(s)
+}
+
+func TestMemory(t *testing.T) {
+	var s = storage.NewMemory()
+	testStorage(t, s, func() storage.Storage {
+		return s
+	})
+}
+
+func TestKV(t *testing.T) {
+	var kv = &memoryKV{data: make(map[string][]byte)}
+	testStorage(t, storage.NewKV(kv), func() storage.Storage {
+		return storage.NewKV(kv)
+	})
+}
+
+func TestFile(t *testing.T) {
+	var dir = t.TempDir()
+	var open = func(opts ...storage.FileOption) storage.Storage {
+		var s, err = storage.NewFile(dir, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	testStorage(t, open(), func() storage.Storage {
+		return open()
+	})
+}
+
+func TestFile_Crash(t *testing.T) {
+	var dir = t.TempDir()
+
+	// 不调用 Close，模拟进程异常退出，只能从日志中恢复
+	var s, _ = storage.NewFile(dir, storage.WithSnapshotInterval(time.Millisecond*10))
+	s.Put(storage.Record{ID: 1, Data: []byte("a")}, storage.Record{ID: 2, Data: []byte("b")})
+	time.Sleep(time.Millisecond * 50)
+	s.Delete(1)
+	s.Put(storage.Record{ID: 3, Data: []byte("c")})
+
+	s, err := storage.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if nRecords := records(t, s); len(nRecords) != 2 || nRecords[0].ID != 2 || nRecords[1].ID != 3 {
+		t.Fatal("恢复的记录与预期不符", nRecords)
+	}
+}
+
+func TestFile_ConcurrentClose(t *testing.T) {
+	var s, err = storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发调用 Close 不能重复关闭内部的 channel
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Close()
+		}()
+	}
+	wg.Wait()
+
+	if err = s.Put(storage.Record{ID: 1}); err != storage.ErrClosed {
+		t.Fatal("关闭之后 Put 应该返回 ErrClosed", err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var dir = t.TempDir()
+	var open = func() storage.Storage {
+		var s, err = storage.NewLog(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	var s = open()
+	s.Put(storage.Record{ID: 1, Data: []byte("a")}, storage.Record{ID: 2, Data: []byte("b")})
+	s.Put(storage.Record{ID: 3, Data: []byte("c")})
+	if err := s.Delete(1, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只能按照顺序写入和删除
+	if err := s.Put(storage.Record{ID: 5, Data: []byte("e")}); !errors.Is(err, storage.ErrNotFIFO) {
+		t.Fatal("Put 不连续的 ID 应该返回 ErrNotFIFO", err)
+	}
+	if err := s.Delete(3); !errors.Is(err, storage.ErrNotFIFO) {
+		t.Fatal("Delete 非头部的记录应该返回 ErrNotFIFO", err)
+	}
+
+	s.Close()
+	s = open()
+
+	if nRecords := records(t, s); len(nRecords) != 2 || nRecords[0].ID != 2 || !bytes.Equal(nRecords[1].Data, []byte("c")) {
+		t.Fatal("恢复的记录与预期不符", nRecords)
+	}
+	s.Put(storage.Record{ID: 4, Data: []byte("d")})
+	s.Delete(2, 3, 4)
+
+	s.Close()
+	s = open()
+	defer s.Close()
+
+	// 日志为空时可以从任意 ID 开始写入
+	if nRecords := records(t, s); len(nRecords) != 0 {
+		t.Fatal("恢复的记录与预期不符", nRecords)
+	}
+	if err := s.Put(storage.Record{ID: 1, Data: []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+	if nRecords := records(t, s); len(nRecords) != 1 || nRecords[0].ID != 1 {
+		t.Fatal("记录与预期不符", nRecords)
+	}
+}
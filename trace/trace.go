@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"context"
+	"time"
+)
+
+// Carrier 用于在元素中保存链路追踪的上下文信息
+// Carrier 的方法与 OpenTelemetry 的 propagation.TextMapCarrier 一致，可以直接传递给 TextMapPropagator 的 Inject 和 Extract 方法
+type Carrier map[string]string
+
+func (c Carrier) Get(key string) string {
+	return c[key]
+}
+
+func (c Carrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c Carrier) Keys() []string {
+	var keys = make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Propagator 用于将 context 中的链路追踪信息写入 Carrier，以及从 Carrier 中恢复链路追踪信息
+// 使用 OpenTelemetry 时，可以参考以下方式实现：
+//
+//	type propagator struct{}
+//
+//	func (propagator) Inject(ctx context.Context, c trace.Carrier) {
+//		otel.GetTextMapPropagator().Inject(ctx, c)
+//	}
+//
+//	func (propagator) Extract(ctx context.Context, c trace.Carrier) context.Context {
+//		return otel.GetTextMapPropagator().Extract(ctx, c)
+//	}
+type Propagator interface {
+	Inject(ctx context.Context, c Carrier)
+
+	Extract(ctx context.Context, c Carrier) context.Context
+}
+
+// Span 由 Tracer 创建的 span
+type Span interface {
+	// End 结束 span，参数 t 为 span 的结束时间
+	End(t time.Time)
+}
+
+// SpanFunc 使用函数实现 Span
+type SpanFunc func(t time.Time)
+
+func (f SpanFunc) End(t time.Time) {
+	f(t)
+}
+
+// Tracer 用于创建元素在队列中等待的 span
+// 使用 OpenTelemetry 时，可以参考以下方式实现：
+//
+//	func (t tracer) Start(ctx context.Context, name string, start time.Time) (context.Context, trace.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, oteltrace.WithTimestamp(start), oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+//		return ctx, trace.SpanFunc(func(end time.Time) { span.End(oteltrace.WithTimestamp(end)) })
+//	}
+type Tracer interface {
+	// Start 创建并开始一个 span，参数 start 为 span 的开始时间
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+type Option func(opts *options)
+
+// WithTracer 用于设定 Tracer，设定之后 Unwrap 会为每一个元素创建一个从入队开始到出队结束的 span
+func WithTracer(tracer Tracer) Option {
+	return func(opts *options) {
+		opts.tracer = tracer
+	}
+}
+
+// WithSpanName 用于设定元素在队列中等待的 span 的名称，默认为 queue wait
+func WithSpanName(name string) Option {
+	return func(opts *options) {
+		if name == "" {
+			name = "queue wait"
+		}
+		opts.name = name
+	}
+}
+
+type options struct {
+	tracer Tracer
+	name   string
+}
+
+// Tracing 链路追踪配置，用于 Wrap 和 Unwrap
+type Tracing struct {
+	propagator Propagator
+	options    *options
+}
+
+func New(p Propagator, opts ...Option) *Tracing {
+	var t = &Tracing{}
+	t.propagator = p
+	t.options = &options{
+		name: "queue wait",
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(t.options)
+		}
+	}
+	return t
+}
+
+// Envelope 携带链路追踪信息的元素，可以作为 block.Queue、delay.Queue 等队列的元素类型
+// 所有字段都是导出的，可以使用 codec.JSON 等编解码器写入持久化队列
+type Envelope[T any] struct {
+	Value      T
+	Carrier    Carrier
+	EnqueuedAt time.Time
+}
+
+// Wrap 在元素入队之前调用，将 ctx 中的链路追踪信息以及当前时间保存到 Envelope 中
+// 如果参数 t 为 nil，则只记录当前时间
+func Wrap[T any](ctx context.Context, t *Tracing, value T) Envelope[T] {
+	var e = Envelope[T]{Value: value, EnqueuedAt: time.Now()}
+	if t != nil && t.propagator != nil {
+		e.Carrier = make(Carrier)
+		t.propagator.Inject(ctx, e.Carrier)
+	}
+	return e
+}
+
+// Unwrap 在元素出队之后调用，从 Envelope 中恢复链路追踪信息，返回的 context 可以用于处理该元素
+// 如果设定了 Tracer，则会创建一个从元素入队开始到当前时间结束的 span，该 span 与返回的 context 中的 span 拥有相同的父 span
+// 如果参数 t 为 nil，则直接返回 ctx
+func Unwrap[T any](ctx context.Context, t *Tracing, e Envelope[T]) (context.Context, T) {
+	if t == nil {
+		return ctx, e.Value
+	}
+
+	if t.propagator != nil && e.Carrier != nil {
+		ctx = t.propagator.Extract(ctx, e.Carrier)
+	}
+
+	if t.options.tracer != nil && !e.EnqueuedAt.IsZero() {
+		var _, span = t.options.tracer.Start(ctx, t.options.name, e.EnqueuedAt)
+		span.End(time.Now())
+	}
+	return ctx, e.Value
+}
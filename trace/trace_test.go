@@ -0,0 +1,94 @@
+package trace_test
+
+import (
+	"context"
+	"github.com/smartwalle/queue/block"
+	"github.com/smartwalle/queue/trace"
+	"testing"
+	"time"
+)
+
+type traceIDKey struct{}
+
+type propagator struct {
+}
+
+func (propagator) Inject(ctx context.Context, c trace.Carrier) {
+	if id, ok := ctx.Value(traceIDKey{}).(string); ok {
+		c.Set("trace-id", id)
+	}
+}
+
+func (propagator) Extract(ctx context.Context, c trace.Carrier) context.Context {
+	if id := c.Get("trace-id"); id != "" {
+		return context.WithValue(ctx, traceIDKey{}, id)
+	}
+	return ctx
+}
+
+type span struct {
+	name       string
+	parent     string
+	start, end time.Time
+}
+
+type tracer struct {
+	spans []*span
+}
+
+func (t *tracer) Start(ctx context.Context, name string, start time.Time) (context.Context, trace.Span) {
+	var s = &span{name: name, start: start}
+	s.parent, _ = ctx.Value(traceIDKey{}).(string)
+	t.spans = append(t.spans, s)
+	return ctx, trace.SpanFunc(func(end time.Time) {
+		s.end = end
+	})
+}
+
+func TestWrapUnwrap(t *testing.T) {
+	var tr = &tracer{}
+	var tracing = trace.New(propagator{}, trace.WithTracer(tr))
+
+	var q = block.New[trace.Envelope[int]]()
+	var ctx = context.WithValue(context.Background(), traceIDKey{}, "abc")
+	q.Enqueue(trace.Wrap(ctx, tracing, 1))
+	q.Enqueue(trace.Wrap(context.Background(), tracing, 2))
+
+	time.Sleep(time.Millisecond * 10)
+
+	var items []trace.Envelope[int]
+	q.Dequeue(&items)
+
+	var nCtx, value = trace.Unwrap(context.Background(), tracing, items[0])
+	if value != 1 || nCtx.Value(traceIDKey{}) != "abc" {
+		t.Fatal("Unwrap 没有恢复链路追踪信息", value, nCtx.Value(traceIDKey{}))
+	}
+
+	nCtx, value = trace.Unwrap(context.Background(), tracing, items[1])
+	if value != 2 || nCtx.Value(traceIDKey{}) != nil {
+		t.Fatal("没有链路追踪信息的元素不应该恢复出链路追踪信息", value, nCtx.Value(traceIDKey{}))
+	}
+
+	if len(tr.spans) != 2 {
+		t.Fatal("每一个元素都应该创建一个 span", len(tr.spans))
+	}
+	var s = tr.spans[0]
+	if s.name != "queue wait" || s.parent != "abc" {
+		t.Fatal("span 与预期不符", s.name, s.parent)
+	}
+	if s.end.Sub(s.start) < time.Millisecond*10 {
+		t.Fatal("span 的时长应该包含元素在队列中等待的时间", s.end.Sub(s.start))
+	}
+}
+
+func TestWrapUnwrap_Nil(t *testing.T) {
+	var e = trace.Wrap(context.Background(), nil, 1)
+	if e.Carrier != nil || e.EnqueuedAt.IsZero() {
+		t.Fatal("参数 t 为 nil 时只应该记录入队时间")
+	}
+
+	var ctx = context.Background()
+	if nCtx, value := trace.Unwrap(ctx, nil, e); nCtx != ctx || value != 1 {
+		t.Fatal("参数 t 为 nil 时应该直接返回 ctx")
+	}
+}
@@ -0,0 +1,287 @@
+package ttl
+
+import (
+	"container/list"
+	"github.com/smartwalle/queue/delay"
+	"sync"
+	"time"
+)
+
+// Reason 元素被淘汰的原因
+type Reason int
+
+const (
+	// Expired 元素已过期
+	Expired Reason = iota
+
+	// Evicted 缓存已满，最久未使用的元素被淘汰
+	Evicted
+
+	// Deleted 元素被 Delete 删除
+	Deleted
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Expired:
+		return "expired"
+	case Evicted:
+		return "evicted"
+	case Deleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+type Option func(opts *options)
+
+// WithDefaultTTL 用于设定 Set 使用的过期时间，默认为 0，小于等于 0 时元素不会过期
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.ttl = ttl
+	}
+}
+
+// WithMaxSize 用于设定缓存的最大容量，缓存已满时会淘汰最久未使用的元素，小于等于 0 时不限制容量
+func WithMaxSize(max int) Option {
+	return func(opts *options) {
+		opts.max = max
+	}
+}
+
+type options struct {
+	ttl time.Duration
+	max int
+}
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	ttl      time.Duration
+	expireAt int64
+}
+
+// Cache 带过期时间的缓存
+// 元素的过期由 delay.KeyedQueue 管理，内部的 goroutine 在元素过期之后将其删除
+type Cache[K comparable, V any] struct {
+	options *options
+	onEvict func(key K, value V, reason Reason)
+	queue   delay.KeyedQueue[K, struct{}]
+	mu      sync.Mutex
+	items   map[K]*list.Element
+	lru     *list.List
+	closed  bool
+	done    chan struct{}
+}
+
+// New 创建缓存，onEvict 在元素过期、被淘汰或者被删除之后调用，可以为 nil
+// 过期触发的 onEvict 在缓存内部的 goroutine 中执行，其它情况在调用 Set、SetWithTTL 或者 Delete 的 goroutine 中执行
+func New[K comparable, V any](onEvict func(key K, value V, reason Reason), opts ...Option) *Cache[K, V] {
+	var c = &Cache[K, V]{}
+	c.options = &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c.options)
+		}
+	}
+	c.onEvict = onEvict
+	c.queue = delay.NewKeyed[K, struct{}](
+		delay.WithTimeUnit(time.Nanosecond),
+		delay.WithTimeProvider(func() int64 {
+			return time.Now().UnixNano()
+		}),
+	)
+	c.items = make(map[K]*list.Element)
+	c.lru = list.New()
+	c.done = make(chan struct{})
+	go c.run()
+	return c
+}
+
+func (c *Cache[K, V]) run() {
+	defer close(c.done)
+
+	for {
+		var key, _, expiration = c.queue.Dequeue()
+		if expiration < 0 {
+			return
+		}
+
+		c.mu.Lock()
+		var ele, ok = c.items[key]
+		if !ok {
+			c.mu.Unlock()
+			continue
+		}
+
+		var e = ele.Value.(*entry[K, V])
+		if e.expireAt == 0 {
+			c.mu.Unlock()
+			continue
+		}
+		if e.expireAt > time.Now().UnixNano() {
+			// 出队之后元素的过期时间被 Touch 或者 Set 延长了
+			c.queue.Upsert(key, struct{}{}, e.expireAt)
+			c.mu.Unlock()
+			continue
+		}
+
+		c.remove(ele)
+		c.mu.Unlock()
+
+		c.evict(e, Expired)
+	}
+}
+
+// Len 获取缓存中元素的数量
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Set 添加或者更新元素，使用 WithDefaultTTL 设定的过期时间
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.options.ttl)
+}
+
+// SetWithTTL 添加或者更新元素，ttl 小于等于 0 时元素不会过期
+// 如果缓存已满，则会淘汰最久未使用的元素
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	var e *entry[K, V]
+	if ele, ok := c.items[key]; ok {
+		e = ele.Value.(*entry[K, V])
+		e.value = value
+		c.lru.MoveToFront(ele)
+	} else {
+		e = &entry[K, V]{key: key, value: value}
+		c.items[key] = c.lru.PushFront(e)
+	}
+	e.ttl = ttl
+	c.schedule(e)
+
+	var evicted *entry[K, V]
+	if c.options.max > 0 && len(c.items) > c.options.max {
+		var back = c.lru.Back()
+		evicted = back.Value.(*entry[K, V])
+		c.remove(back)
+	}
+	c.mu.Unlock()
+
+	if evicted != nil {
+		c.evict(evicted, Evicted)
+	}
+}
+
+// Get 获取元素，并将其标记为最近使用，不会延长元素的过期时间
+// 已过期但是还没有被内部的 goroutine 删除的元素会被视为不存在，并在本方法中删除
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	var ele, expired = c.lookup(key)
+	if ele == nil {
+		c.mu.Unlock()
+		c.evict(expired, Expired)
+		var value V
+		return value, false
+	}
+	c.lru.MoveToFront(ele)
+	var value = ele.Value.(*entry[K, V]).value
+	c.mu.Unlock()
+	return value, true
+}
+
+// Touch 使用元素设定时的 ttl 重新计算其过期时间，并将其标记为最近使用
+// 如果缓存中不存在该元素或者该元素已过期，则返回 false
+func (c *Cache[K, V]) Touch(key K) bool {
+	c.mu.Lock()
+	var ele, expired = c.lookup(key)
+	if ele == nil {
+		c.mu.Unlock()
+		c.evict(expired, Expired)
+		return false
+	}
+	c.lru.MoveToFront(ele)
+	c.schedule(ele.Value.(*entry[K, V]))
+	c.mu.Unlock()
+	return true
+}
+
+// lookup 获取 key 对应的元素，如果元素已过期，则将其删除并作为第二个返回值返回，调用方需要持有锁
+func (c *Cache[K, V]) lookup(key K) (*list.Element, *entry[K, V]) {
+	var ele, ok = c.items[key]
+	if !ok {
+		return nil, nil
+	}
+	var e = ele.Value.(*entry[K, V])
+	if e.expireAt != 0 && e.expireAt <= time.Now().UnixNano() {
+		c.remove(ele)
+		return nil, e
+	}
+	return ele, nil
+}
+
+// Delete 删除元素，如果缓存中不存在该元素，则返回 false
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	var ele, ok = c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	c.remove(ele)
+	c.mu.Unlock()
+
+	c.evict(ele.Value.(*entry[K, V]), Deleted)
+	return true
+}
+
+// Close 停止过期处理并清空缓存，不会为剩余的元素调用 onEvict
+// 关闭之后 Set 和 SetWithTTL 不会再添加元素
+func (c *Cache[K, V]) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.queue.Close()
+	<-c.done
+
+	c.mu.Lock()
+	c.items = make(map[K]*list.Element)
+	c.lru.Init()
+	c.mu.Unlock()
+}
+
+// schedule 根据元素的 ttl 更新其过期时间，调用方需要持有锁
+func (c *Cache[K, V]) schedule(e *entry[K, V]) {
+	if e.ttl <= 0 {
+		e.expireAt = 0
+		c.queue.CancelByKey(e.key)
+		return
+	}
+	e.expireAt = time.Now().UnixNano() + int64(e.ttl)
+	c.queue.Upsert(e.key, struct{}{}, e.expireAt)
+}
+
+// remove 调用方需要持有锁
+func (c *Cache[K, V]) remove(ele *list.Element) {
+	var e = ele.Value.(*entry[K, V])
+	delete(c.items, e.key)
+	c.lru.Remove(ele)
+	c.queue.CancelByKey(e.key)
+}
+
+func (c *Cache[K, V]) evict(e *entry[K, V], reason Reason) {
+	if e != nil && c.onEvict != nil {
+		c.onEvict(e.key, e.value, reason)
+	}
+}
@@ -0,0 +1,135 @@
+package ttl_test
+
+import (
+	"github.com/smartwalle/queue/ttl"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eviction struct {
+	key    string
+	value  int
+	reason ttl.Reason
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	evictions []eviction
+}
+
+func (r *recorder) onEvict(key string, value int, reason ttl.Reason) {
+	r.mu.Lock()
+	r.evictions = append(r.evictions, eviction{key: key, value: value, reason: reason})
+	r.mu.Unlock()
+}
+
+func (r *recorder) get() []eviction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]eviction(nil), r.evictions...)
+}
+
+func TestCache_Expire(t *testing.T) {
+	var r = &recorder{}
+	var c = ttl.New[string, int](r.onEvict, ttl.WithDefaultTTL(time.Millisecond*20))
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, 0)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatal("Get 获取到的元素与预期不符", v, ok)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("元素过期之后应该被删除")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("ttl 为 0 的元素不应该过期")
+	}
+
+	var evictions = r.get()
+	if len(evictions) != 1 || evictions[0] != (eviction{key: "a", value: 1, reason: ttl.Expired}) {
+		t.Fatal("onEvict 与预期不符", evictions)
+	}
+}
+
+func TestCache_GetExpired(t *testing.T) {
+	var r = &recorder{}
+	var c = ttl.New[string, int](r.onEvict)
+
+	// 刚刚过期的元素即使还没有被内部的 goroutine 删除，Get 也不应该返回该元素
+	c.SetWithTTL("a", 1, time.Millisecond)
+	var deadline = time.Now().Add(time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	if v, ok := c.Get("a"); ok {
+		t.Fatal("Get 不应该返回已过期的元素", v)
+	}
+	if c.Touch("a") {
+		t.Fatal("Touch 不应该延长已过期的元素")
+	}
+	if c.Len() != 0 {
+		t.Fatal("已过期的元素应该被删除", c.Len())
+	}
+	c.Close()
+
+	var evictions = r.get()
+	if len(evictions) != 1 || evictions[0] != (eviction{key: "a", value: 1, reason: ttl.Expired}) {
+		t.Fatal("onEvict 与预期不符", evictions)
+	}
+}
+
+func TestCache_Touch(t *testing.T) {
+	var c = ttl.New[string, int](nil)
+	defer c.Close()
+
+	c.SetWithTTL("a", 1, time.Millisecond*40)
+	for i := 0; i < 4; i++ {
+		time.Sleep(time.Millisecond * 20)
+		if !c.Touch("a") {
+			t.Fatal("Touch 之后元素不应该过期")
+		}
+	}
+
+	time.Sleep(time.Millisecond * 80)
+	if c.Touch("a") || c.Len() != 0 {
+		t.Fatal("不再 Touch 之后元素应该过期")
+	}
+}
+
+func TestCache_MaxSize(t *testing.T) {
+	var r = &recorder{}
+	var c = ttl.New[string, int](r.onEvict, ttl.WithMaxSize(2))
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("缓存已满时应该淘汰最久未使用的元素")
+	}
+	if c.Len() != 2 {
+		t.Fatal("缓存中元素的数量与预期不符", c.Len())
+	}
+
+	c.Delete("a")
+	var evictions = r.get()
+	if len(evictions) != 2 || evictions[0].reason != ttl.Evicted || evictions[1] != (eviction{key: "a", value: 1, reason: ttl.Deleted}) {
+		t.Fatal("onEvict 与预期不符", evictions)
+	}
+}
+
+func TestCache_Close(t *testing.T) {
+	var c = ttl.New[string, int](nil, ttl.WithDefaultTTL(time.Hour))
+	c.Set("a", 1)
+	c.Close()
+
+	c.Set("b", 2)
+	if c.Len() != 0 {
+		t.Fatal("关闭之后缓存应该为空", c.Len())
+	}
+}
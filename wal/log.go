@@ -0,0 +1,475 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed  = errors.New("wal: log closed")
+	ErrCorrupt = errors.New("wal: log corrupt")
+)
+
+const (
+	segmentExt  = ".wal"
+	metaFile    = "meta"
+	headerSize  = 8
+	defaultSize = 64 << 20
+)
+
+// SyncPolicy 日志写入磁盘的策略
+type SyncPolicy int
+
+const (
+	// SyncAlways 每一次写入之后都调用 fsync
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval 按照固定的时间间隔调用 fsync
+	SyncInterval
+
+	// SyncNever 从不主动调用 fsync，由操作系统决定何时写入磁盘
+	SyncNever
+)
+
+type Option func(opts *options)
+
+// WithSyncPolicy 用于设定日志写入磁盘的策略，默认为 SyncAlways
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(opts *options) {
+		opts.policy = policy
+	}
+}
+
+// WithSyncInterval 用于设定 SyncInterval 策略的时间间隔，默认为 1 秒
+func WithSyncInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		opts.interval = interval
+	}
+}
+
+// WithSegmentSize 用于设定单个日志分段文件的大小，超过该大小之后会创建新的分段文件，默认为 64MB
+func WithSegmentSize(size int64) Option {
+	return func(opts *options) {
+		if size <= 0 {
+			size = defaultSize
+		}
+		opts.segmentSize = size
+	}
+}
+
+type options struct {
+	policy      SyncPolicy
+	interval    time.Duration
+	segmentSize int64
+}
+
+type segment struct {
+	index uint64
+	path  string
+}
+
+// Log 分段存储的预写日志
+// 日志中的每一条记录都有一个从 1 开始递增的序号，记录只能追加到日志末尾，或者从日志头部截断
+type Log struct {
+	mu       sync.Mutex
+	dir      string
+	options  *options
+	segments []segment
+	file     *os.File
+	size     int64
+	first    uint64
+	last     uint64
+	dirty    bool
+	closed   bool
+	done     chan struct{}
+}
+
+// Open 打开 dir 目录中的日志，如果目录不存在则创建
+// 最后一个分段文件末尾不完整的记录会被丢弃
+func Open(dir string, opts ...Option) (*Log, error) {
+	var l = &Log{}
+	l.dir = dir
+	l.options = &options{
+		policy:      SyncAlways,
+		interval:    time.Second,
+		segmentSize: defaultSize,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(l.options)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	if l.options.policy == SyncInterval {
+		l.done = make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+func (l *Log) load() error {
+	l.first = 1
+	if data, err := os.ReadFile(filepath.Join(l.dir, metaFile)); err == nil && len(data) == 8 {
+		l.first = binary.BigEndian.Uint64(data)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var entries, err = os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		var name = entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		var index, err = strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.segments = append(l.segments, segment{index: index, path: filepath.Join(l.dir, name)})
+	}
+	sort.Slice(l.segments, func(i, j int) bool {
+		return l.segments[i].index < l.segments[j].index
+	})
+
+	if len(l.segments) == 0 {
+		l.last = l.first - 1
+		return l.create(l.first)
+	}
+
+	// 只有最后一个分段文件需要扫描，其它分段文件的记录数量由相邻分段文件的起始序号确定
+	var tail = l.segments[len(l.segments)-1]
+	var count, offset, sErr = scan(tail.path, nil)
+	if sErr != nil && !errors.Is(sErr, ErrCorrupt) {
+		return sErr
+	}
+	if l.file, err = os.OpenFile(tail.path, os.O_RDWR, 0644); err != nil {
+		return err
+	}
+	if err = l.file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err = l.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	l.size = offset
+	l.last = tail.index + count - 1
+
+	if l.first < l.segments[0].index {
+		l.first = l.segments[0].index
+	}
+	if l.first > l.last+1 {
+		l.first = l.last + 1
+	}
+	return nil
+}
+
+func (l *Log) create(index uint64) error {
+	var path = filepath.Join(l.dir, fmt.Sprintf("%020d%s", index, segmentExt))
+	var file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	l.segments = append(l.segments, segment{index: index, path: path})
+	l.file = file
+	l.size = 0
+	return nil
+}
+
+// FirstIndex 获取日志中第一条记录的序号，日志为空时返回值为 LastIndex() + 1
+func (l *Log) FirstIndex() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.first
+}
+
+// LastIndex 获取日志中最后一条记录的序号
+func (l *Log) LastIndex() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// Append 追加记录到日志末尾，返回最后一条记录的序号
+func (l *Log) Append(records ...[]byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+	if len(records) == 0 {
+		return l.last, nil
+	}
+
+	if l.size >= l.options.segmentSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	var n = 0
+	for _, record := range records {
+		n += headerSize + len(record)
+	}
+	var buf = make([]byte, n)
+	var pos = 0
+	for _, record := range records {
+		binary.BigEndian.PutUint32(buf[pos:], uint32(len(record)))
+		binary.BigEndian.PutUint32(buf[pos+4:], crc32.ChecksumIEEE(record))
+		copy(buf[pos+headerSize:], record)
+		pos += headerSize + len(record)
+	}
+
+	if _, err := l.file.Write(buf); err != nil {
+		l.discard()
+		return 0, err
+	}
+
+	switch l.options.policy {
+	case SyncAlways:
+		if err := l.file.Sync(); err != nil {
+			// 写入磁盘失败的记录不能在重新打开日志时被恢复
+			l.discard()
+			return 0, err
+		}
+	case SyncInterval:
+		l.dirty = true
+	}
+	l.size += int64(n)
+	l.last += uint64(len(records))
+	return l.last, nil
+}
+
+// discard 写入失败时丢弃本次写入的记录，保证日志末尾的完整性，调用方需要持有锁
+func (l *Log) discard() {
+	l.file.Truncate(l.size)
+	l.file.Seek(l.size, io.SeekStart)
+}
+
+func (l *Log) rotate() error {
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	return l.create(l.last + 1)
+}
+
+// Replay 按顺序读取日志中序号大于等于 from 的所有记录
+// 参数 fn 返回错误时会停止读取，并返回该错误；fn 中不能调用 Log 的其它方法
+func (l *Log) Replay(from uint64, fn func(index uint64, data []byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if from < l.first {
+		from = l.first
+	}
+
+	for i, seg := range l.segments {
+		if i+1 < len(l.segments) && l.segments[i+1].index <= from {
+			continue
+		}
+
+		var index = seg.index - 1
+		var count, _, err = scan(seg.path, func(data []byte) error {
+			index++
+			if index < from || index > l.last {
+				return nil
+			}
+			return fn(index, data)
+		})
+		if err != nil {
+			return err
+		}
+		if i+1 < len(l.segments) && seg.index+count != l.segments[i+1].index {
+			return ErrCorrupt
+		}
+	}
+	return nil
+}
+
+// TruncateFront 丢弃日志中序号小于 index 的所有记录
+// 只包含被丢弃记录的分段文件会被删除
+func (l *Log) TruncateFront(index uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if index > l.last+1 {
+		index = l.last + 1
+	}
+	if index <= l.first {
+		return nil
+	}
+
+	l.first = index
+	if err := l.writeMeta(); err != nil {
+		return err
+	}
+
+	var n = 0
+	for n+1 < len(l.segments) && l.segments[n+1].index <= index {
+		if err := os.Remove(l.segments[n].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		n++
+	}
+	l.segments = l.segments[n:]
+	return nil
+}
+
+func (l *Log) writeMeta() error {
+	var path = filepath.Join(l.dir, metaFile)
+	var tmp = path + ".tmp"
+
+	var data = make([]byte, 8)
+	binary.BigEndian.PutUint64(data, l.first)
+	var file, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if l.options.policy == SyncAlways {
+		if err = file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Sync 将日志写入磁盘
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	l.dirty = false
+	return l.file.Sync()
+}
+
+// Close 将日志写入磁盘并关闭日志
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	var err = l.file.Sync()
+	if cErr := l.file.Close(); err == nil {
+		err = cErr
+	}
+	l.mu.Unlock()
+
+	if l.done != nil {
+		close(l.done)
+	}
+	return err
+}
+
+func (l *Log) syncLoop() {
+	var ticker = time.NewTicker(l.options.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if !l.closed && l.dirty {
+				l.dirty = false
+				l.file.Sync()
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// scan 读取分段文件中的所有记录，返回完整记录的数量以及最后一条完整记录的结束位置
+// 遇到不完整或者校验失败的记录时停止读取，并返回 ErrCorrupt；记录头中的长度超过文件剩余的字节数时视为不完整的记录
+func scan(path string, fn func(data []byte) error) (uint64, int64, error) {
+	var file, err = os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var info os.FileInfo
+	if info, err = file.Stat(); err != nil {
+		return 0, 0, err
+	}
+
+	var reader = bufio.NewReader(file)
+	var count uint64
+	var offset int64
+	var header = make([]byte, headerSize)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return count, offset, nil
+			}
+			return count, offset, ErrCorrupt
+		}
+
+		var size = binary.BigEndian.Uint32(header[0:4])
+		var sum = binary.BigEndian.Uint32(header[4:8])
+		if int64(size) > info.Size()-offset-headerSize {
+			return count, offset, ErrCorrupt
+		}
+		var data = make([]byte, size)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return count, offset, ErrCorrupt
+		}
+		if crc32.ChecksumIEEE(data) != sum {
+			return count, offset, ErrCorrupt
+		}
+
+		if fn != nil {
+			if err = fn(data); err != nil {
+				return count, offset, err
+			}
+		}
+		count++
+		offset += int64(headerSize + len(data))
+	}
+}
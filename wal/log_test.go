@@ -0,0 +1,147 @@
+package wal_test
+
+import (
+	"fmt"
+	"github.com/smartwalle/queue/wal"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func BenchmarkLog_Append(b *testing.B) {
+	var l, err = wal.Open(b.TempDir(), wal.WithSyncPolicy(wal.SyncNever))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+
+	var data = make([]byte, 128)
+	for i := 0; i < b.N; i++ {
+		l.Append(data)
+	}
+}
+
+func replay(t *testing.T, l *wal.Log) []string {
+	var records []string
+	if err := l.Replay(0, func(index uint64, data []byte) error {
+		records = append(records, string(data))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestLog_Reopen(t *testing.T) {
+	var dir = t.TempDir()
+
+	var l, err = wal.Open(dir, wal.WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err = l.Append([]byte(fmt.Sprintf("record-%02d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = l.TruncateFront(8); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	if l, err = wal.Open(dir, wal.WithSegmentSize(64)); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.FirstIndex() != 8 || l.LastIndex() != 20 {
+		t.Fatal("重新打开之后日志的序号与预期不符", l.FirstIndex(), l.LastIndex())
+	}
+
+	var records = replay(t, l)
+	if len(records) != 13 || records[0] != "record-07" || records[12] != "record-19" {
+		t.Fatal("重新打开之后读取到的记录与预期不符", records)
+	}
+
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segments) >= 10 {
+		t.Fatal("TruncateFront 应该删除只包含被丢弃记录的分段文件", len(segments))
+	}
+}
+
+func TestLog_TornWrite(t *testing.T) {
+	var dir = t.TempDir()
+
+	var l, err = wal.Open(dir, wal.WithSyncPolicy(wal.SyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([]byte("a"), []byte("b"))
+	l.Close()
+
+	// 模拟写入过程中进程退出，最后一条记录不完整
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*.wal"))
+	var file, _ = os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write([]byte{0, 0, 0, 9, 1, 2})
+	file.Close()
+
+	if l, err = wal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.LastIndex() != 2 {
+		t.Fatal("不完整的记录应该被丢弃", l.LastIndex())
+	}
+
+	l.Append([]byte("c"))
+	if records := replay(t, l); len(records) != 3 || records[2] != "c" {
+		t.Fatal("读取到的记录与预期不符", records)
+	}
+}
+
+func TestLog_CorruptLength(t *testing.T) {
+	var dir = t.TempDir()
+
+	var l, err = wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([]byte("a"), []byte("b"))
+	l.Close()
+
+	// 记录头中的长度远大于文件剩余的字节数，不应该按照该长度分配内存
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*.wal"))
+	var file, _ = os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write([]byte{0xff, 0xff, 0xff, 0xf0, 0, 0, 0, 0, 1, 2, 3})
+	file.Close()
+
+	if l, err = wal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if records := replay(t, l); len(records) != 2 || l.LastIndex() != 2 {
+		t.Fatal("长度错误的记录应该被丢弃", records, l.LastIndex())
+	}
+}
+
+func TestLog_TruncateAll(t *testing.T) {
+	var dir = t.TempDir()
+
+	var l, _ = wal.Open(dir)
+	l.Append([]byte("a"), []byte("b"))
+	l.TruncateFront(100)
+	l.Close()
+
+	l, _ = wal.Open(dir)
+	defer l.Close()
+
+	if l.FirstIndex() != 3 || l.LastIndex() != 2 || len(replay(t, l)) != 0 {
+		t.Fatal("日志应该为空", l.FirstIndex(), l.LastIndex())
+	}
+
+	if index, _ := l.Append([]byte("c")); index != 3 {
+		t.Fatal("记录的序号应该继续递增", index)
+	}
+}